@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -26,6 +27,9 @@ type Client struct {
 	ContentType string
 	Token       string
 	APIBaseURL  string
+	Retry       RetryPolicy
+	// UserAgent overrides the package's UserAgent for this client's requests.
+	UserAgent string
 }
 
 // NewClient returns an Exercism API client.
@@ -39,16 +43,25 @@ func NewClient(token, baseURL string) (*Client, error) {
 
 // NewRequest returns an http.Request with information for the Exercism API.
 func (c *Client) NewRequest(method, url string, body io.Reader) (*http.Request, error) {
+	return c.NewRequestWithContext(context.Background(), method, url, body)
+}
+
+// NewRequestWithContext is like NewRequest, but the request is canceled with the context.
+func (c *Client) NewRequestWithContext(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
 	if c.Client == nil {
 		c.Client = HTTPClient
 	}
 
-	req, err := http.NewRequest(method, url, body)
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", UserAgent)
+	if c.UserAgent == "" {
+		req.Header.Set("User-Agent", UserAgent)
+	} else {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
 	if c.ContentType == "" {
 		req.Header.Set("Content-Type", "application/json")
 	} else {
@@ -62,26 +75,40 @@ func (c *Client) NewRequest(method, url string, body io.Reader) (*http.Request,
 }
 
 // Do performs an http.Request and optionally parses the response body into the given interface.
+// Transient failures are retried according to the client's retry policy.
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
-	debug.DumpRequest(req)
-
-	res, err := c.Client.Do(req)
-	if err != nil {
-		return nil, err
+	for retry := 0; ; retry++ {
+		debug.DumpRequest(req)
+
+		res, err := c.Client.Do(req)
+		delay, ok := c.Retry.backoff(req, res, err, retry)
+		if !ok {
+			if err != nil {
+				return nil, err
+			}
+			debug.DumpResponse(res)
+			return res, nil
+		}
+
+		if err == nil {
+			debug.DumpResponse(res)
+			discard(res)
+		}
+		debug.Printf("retrying %s %s in %s\n", req.Method, req.URL, delay)
+		if err := sleep(req.Context(), delay); err != nil {
+			return nil, err
+		}
 	}
-
-	debug.DumpResponse(res)
-	return res, nil
 }
 
 // TokenIsValid calls the API to determine whether the token is valid.
 func (c *Client) TokenIsValid() (bool, error) {
-	url := fmt.Sprintf("%s/validate_token", c.APIBaseURL)
-	req, err := c.NewRequest("GET", url, nil)
-	if err != nil {
-		return false, err
-	}
-	resp, err := c.Do(req)
+	return c.TokenIsValidWithContext(context.Background())
+}
+
+// TokenIsValidWithContext is like TokenIsValid, but the request is canceled with the context.
+func (c *Client) TokenIsValidWithContext(ctx context.Context) (bool, error) {
+	resp, err := c.ValidateToken(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -90,6 +117,18 @@ func (c *Client) TokenIsValid() (bool, error) {
 	return resp.StatusCode == http.StatusOK, nil
 }
 
+// ValidateToken calls the API to check the token, and returns its response,
+// so that a rejected token can be told apart from an API that can't say.
+// The caller must close the response body.
+func (c *Client) ValidateToken(ctx context.Context) (*http.Response, error) {
+	url := fmt.Sprintf("%s/validate_token", c.APIBaseURL)
+	req, err := c.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
 // IsPingable calls the API /ping to determine whether the API can be reached.
 func (c *Client) IsPingable() error {
 	url := fmt.Sprintf("%s/ping", c.APIBaseURL)
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -53,6 +55,33 @@ func TestNewRequestSetsDefaultHeaders(t *testing.T) {
 	}
 }
 
+func TestNewRequestWithContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the canceled request shouldn't reach the server")
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	client := &Client{Token: "abc123"}
+	req, err := client.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer abc123", req.Header.Get("Authorization"))
+
+	_, err = client.Do(req)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestNewRequestWithUserAgent(t *testing.T) {
+	UserAgent = "BogusAgent"
+
+	client := &Client{UserAgent: "CustomAgent/1.0"}
+	req, err := client.NewRequest("GET", "http://example.com", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "CustomAgent/1.0", req.Header.Get("User-Agent"))
+}
+
 func TestDo(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "GET", r.Method)
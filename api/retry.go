@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	// DefaultRetryBaseDelay is the delay before the first retry when a policy doesn't set one.
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay caps the delay between retries when a policy doesn't set one.
+	DefaultRetryMaxDelay = 8 * time.Second
+)
+
+// RetryPolicy configures how a client retries requests that fail transiently.
+// Only GET requests are retried, after a connection error, a 5xx response, or
+// a 429 Too Many Requests response. A Retry-After header is honored, unless it
+// asks for a longer wait than the maximum delay.
+// The zero value disables retries.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+func (p RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelay <= 0 {
+		return DefaultRetryMaxDelay
+	}
+	return p.MaxDelay
+}
+
+// delay is the exponential backoff before the given retry (starting at 0).
+func (p RetryPolicy) delay(retry int) time.Duration {
+	base, max := p.BaseDelay, p.maxDelay()
+	if base <= 0 {
+		base = DefaultRetryBaseDelay
+	}
+	d := base
+	for i := 0; i < retry && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		return max
+	}
+	return d
+}
+
+// backoff decides whether a request should be retried, and how long to wait before doing so.
+func (p RetryPolicy) backoff(req *http.Request, res *http.Response, err error, retry int) (time.Duration, bool) {
+	if retry >= p.MaxRetries || req.Method != http.MethodGet {
+		return 0, false
+	}
+	if err != nil {
+		return p.delay(retry), req.Context().Err() == nil
+	}
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode < 500 {
+		return 0, false
+	}
+	if after, ok := retryAfter(res); ok {
+		return after, after <= p.maxDelay()
+	}
+	return p.delay(retry), true
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as a date.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	header := res.Header.Get("Retry-After")
+	if header == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0, true
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleep waits for d, returning early with an error if the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// discard drains and closes a response body so the connection can be reused.
+func discard(res *http.Response) {
+	io.Copy(ioutil.Discard, res.Body)
+	res.Body.Close()
+}
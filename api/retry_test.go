@@ -0,0 +1,221 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoRetriesServerErrors(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	client := &Client{Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}}
+
+	req, err := client.NewRequest("GET", ts.URL, nil)
+	assert.NoError(t, err)
+
+	res, err := client.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestDoRetriesConnectionErrors(t *testing.T) {
+	var attempts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	client := &Client{Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}}
+
+	req, err := client.NewRequest("GET", ts.URL, nil)
+	assert.NoError(t, err)
+
+	res, err := client.Do(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestDoDoesNotRetry(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		method string
+		status int
+		policy RetryPolicy
+	}{
+		{
+			desc:   "client errors",
+			method: "GET",
+			status: http.StatusNotFound,
+			policy: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+		},
+		{
+			desc:   "unauthorized",
+			method: "GET",
+			status: http.StatusUnauthorized,
+			policy: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+		},
+		{
+			desc:   "non-GET requests",
+			method: "PATCH",
+			status: http.StatusInternalServerError,
+			policy: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+		},
+		{
+			desc:   "zero value policy",
+			method: "GET",
+			status: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(tc.status)
+			}))
+			defer ts.Close()
+
+			client := &Client{Retry: tc.policy}
+
+			req, err := client.NewRequest(tc.method, ts.URL, nil)
+			assert.NoError(t, err)
+
+			res, err := client.Do(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, res.StatusCode)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+		})
+	}
+}
+
+func TestDoRetryBackoffIsInterruptible(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client := &Client{Retry: RetryPolicy{MaxRetries: 5, BaseDelay: time.Hour}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, err := client.NewRequest("GET", ts.URL, nil)
+	assert.NoError(t, err)
+
+	_, err = client.Do(req.WithContext(ctx))
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestDoRetriesRateLimits(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		retryAfter string
+		attempts   int32
+		status     int
+		minElapsed time.Duration
+	}{
+		{
+			desc:       "honors Retry-After",
+			retryAfter: "1",
+			attempts:   2,
+			status:     http.StatusOK,
+			minElapsed: time.Second,
+		},
+		{
+			desc:     "backs off without Retry-After",
+			attempts: 2,
+			status:   http.StatusOK,
+		},
+		{
+			desc:       "gives up when Retry-After exceeds the max delay",
+			retryAfter: "3600",
+			attempts:   1,
+			status:     http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var attempts int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&attempts, 1) == 1 {
+					if tc.retryAfter != "" {
+						w.Header().Set("Retry-After", tc.retryAfter)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				fmt.Fprint(w, "ok")
+			}))
+			defer ts.Close()
+
+			client := &Client{Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}}
+
+			req, err := client.NewRequest("GET", ts.URL, nil)
+			assert.NoError(t, err)
+
+			start := time.Now()
+			res, err := client.Do(req)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.status, res.StatusCode)
+			assert.Equal(t, tc.attempts, atomic.LoadInt32(&attempts))
+			assert.True(t, time.Since(start) >= tc.minElapsed)
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	testCases := []struct {
+		header   string
+		expected time.Duration
+		ok       bool
+	}{
+		{header: "", expected: 0, ok: false},
+		{header: "5", expected: 5 * time.Second, ok: true},
+		{header: "-5", expected: 0, ok: true},
+		{header: "Wed, 21 Oct 2015 07:28:00 GMT", expected: 0, ok: true},
+		{header: "soon", expected: 0, ok: false},
+	}
+
+	for _, tc := range testCases {
+		res := &http.Response{Header: http.Header{}}
+		res.Header.Set("Retry-After", tc.header)
+
+		d, ok := retryAfter(res)
+		assert.Equal(t, tc.expected, d, tc.header)
+		assert.Equal(t, tc.ok, ok, tc.header)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	assert.Equal(t, time.Second, policy.delay(0))
+	assert.Equal(t, 2*time.Second, policy.delay(1))
+	assert.Equal(t, 4*time.Second, policy.delay(2))
+	assert.Equal(t, 5*time.Second, policy.delay(3))
+	assert.Equal(t, 5*time.Second, policy.delay(100))
+}
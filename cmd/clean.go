@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/exercism/cli/workspace"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// cleanCmd removes a downloaded exercise from the workspace.
+var cleanCmd = &cobra.Command{
+	Use:   "clean DIR",
+	Short: "Delete a downloaded exercise.",
+	Long: `Delete the directory of a downloaded exercise, and everything in it.
+
+Only directories with exercise metadata are deleted, so that an
+unrelated directory can't be removed by mistake.
+
+You're asked to confirm before anything is deleted, unless you pass --yes.
+`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runClean(cmd.Flags(), args)
+	},
+}
+
+func runClean(flags *pflag.FlagSet, args []string) error {
+	yes, err := flags.GetBool("yes")
+	if err != nil {
+		return err
+	}
+
+	dir, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	exercise := workspace.NewExerciseFromDir(dir)
+	ok, err := exercise.HasMetadata()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return withKind(ErrMissingMetadata, fmt.Errorf("refusing to delete '%s', it has no exercise metadata", dir))
+	}
+	metadata, err := workspace.NewExerciseMetadata(exercise.MetadataDir())
+	if err != nil {
+		return fmt.Errorf("refusing to delete '%s', its exercise metadata can't be read: %w", dir, err)
+	}
+
+	if !yes {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("refusing to delete '%s' without confirmation, pass --yes to delete it", dir)
+		}
+		fmt.Fprintf(Err, "\nDelete %s in '%s'? [y]es, [n]o: ", metadata, dir)
+		answer, _ := bufio.NewReader(In).ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+		default:
+			return fmt.Errorf("'%s' was not deleted", dir)
+		}
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	fmt.Fprintf(Err, "\nDeleted\n")
+	fmt.Fprintf(Out, "%s\n", dir)
+	return nil
+}
+
+func setupCleanFlags(flags *pflag.FlagSet) {
+	flags.BoolP("yes", "y", false, "delete without asking for confirmation")
+}
+
+func init() {
+	RootCmd.AddCommand(cleanCmd)
+	setupCleanFlags(cleanCmd.Flags())
+}
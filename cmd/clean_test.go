@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/exercism/cli/workspace"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClean(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		yes         bool
+		interactive bool
+		answer      string
+		deleted     bool
+		err         string
+	}{
+		{desc: "with --yes", yes: true, deleted: true},
+		{desc: "confirmed", interactive: true, answer: "y\n", deleted: true},
+		{desc: "declined", interactive: true, answer: "n\n", err: "was not deleted"},
+		{desc: "without a terminal", err: "without confirmation"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			oldIn, oldIsTerminal := In, stdinIsTerminal
+			defer func() { In, stdinIsTerminal = oldIn, oldIsTerminal }()
+			In = strings.NewReader(tc.answer)
+			stdinIsTerminal = func() bool { return tc.interactive }
+
+			tmpDir, err := ioutil.TempDir("", "clean")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			metadata := &workspace.ExerciseMetadata{ID: "bogus-id", Track: "bogus-track", ExerciseSlug: "bogus-exercise"}
+			err = metadata.Write(dir)
+			assert.NoError(t, err)
+			err = ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("contents"), os.FileMode(0644))
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupCleanFlags(flags)
+			if tc.yes {
+				flags.Set("yes", "true")
+			}
+
+			err = runClean(flags, []string{dir})
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Regexp(t, tc.err, err.Error())
+			}
+
+			_, err = os.Stat(dir)
+			assert.Equal(t, tc.deleted, os.IsNotExist(err))
+			_, err = os.Stat(filepath.Join(tmpDir, "bogus-track"))
+			assert.NoError(t, err)
+
+			// The question is asked on Err, so Out only ever holds the deleted directory.
+			assert.Equal(t, tc.interactive, strings.Contains(Err.(*bytes.Buffer).String(), "[y]es, [n]o"))
+			if tc.deleted {
+				assert.Equal(t, dir+"\n", Out.(*bytes.Buffer).String())
+			} else {
+				assert.Equal(t, "", Out.(*bytes.Buffer).String())
+			}
+		})
+	}
+}
+
+func TestCleanWithoutMetadata(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "clean-without-metadata")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	file := filepath.Join(tmpDir, "important.txt")
+	err = ioutil.WriteFile(file, []byte("contents"), os.FileMode(0644))
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupCleanFlags(flags)
+	flags.Set("yes", "true")
+
+	err = runClean(flags, []string{tmpDir})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "has no exercise metadata", err.Error())
+		assert.True(t, errors.Is(err, ErrMissingMetadata))
+	}
+
+	_, err = os.Stat(file)
+	assert.NoError(t, err)
+}
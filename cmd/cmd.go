@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
 	"strings"
+	"syscall"
 
 	"io"
 
@@ -23,6 +29,14 @@ var (
 	Out io.Writer
 	// Err is used to write errors.
 	Err io.Writer
+	// In is used to read interactive responses.
+	In io.Reader
+	// noColor turns off colored output, as does the NO_COLOR environment variable.
+	noColor bool
+	// quiet turns off informational output, leaving the results and the errors.
+	quiet bool
+	// insecure skips the verification of TLS certificates, for testing against a local site.
+	insecure bool
 )
 
 const msgWelcomePleaseConfigure = `
@@ -52,6 +66,26 @@ const msgRerunConfigure = `
         %s configure
 `
 
+const msgUnauthorized = `
+
+    The API didn't accept your token. It may have been mistyped, or reset.
+    Find your token on the settings page of the website, then run the
+    configure command:
+
+        %s configure --token=YOUR_TOKEN
+
+`
+
+const msgMalformedToken = `
+
+    Your API token doesn't look right. It should be the token exactly as
+    shown on the settings page of the website, without quotes or spaces.
+    Copy it again, then run the configure command:
+
+        %s configure --token=YOUR_TOKEN
+
+`
+
 const msgMissingMetadata = `
 
     The exercise you are submitting doesn't have the necessary metadata.
@@ -59,43 +93,192 @@ const msgMissingMetadata = `
 
 `
 
-// validateUserConfig validates the presence of required user config values
+// rgxToken matches the characters that an API token is made of.
+var rgxToken = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// validateUserConfig validates the presence of required user config values.
+// Whitespace around the token is ignored, as when it was pasted with a newline,
+// and a token that can't be right is rejected before it's sent to the API.
+// The config itself is left as it is, so the token is trimmed where it's read.
 func validateUserConfig(cfg *viper.Viper) error {
-	if cfg.GetString("token") == "" {
-		return fmt.Errorf(
+	token := strings.TrimSpace(cfg.GetString("token"))
+	if token == "" {
+		return withKind(ErrMissingConfig, fmt.Errorf(
 			msgWelcomePleaseConfigure,
 			config.SettingsURL(cfg.GetString("apibaseurl")),
 			BinaryName,
-		)
+		))
 	}
-	if cfg.GetString("workspace") == "" || cfg.GetString("apibaseurl") == "" {
-		return fmt.Errorf(msgRerunConfigure, BinaryName)
+	if !rgxToken.MatchString(token) {
+		return withKind(ErrMissingConfig, fmt.Errorf(msgMalformedToken, BinaryName))
+	}
+	if userWorkspace(cfg) == "" || cfg.GetString("apibaseurl") == "" {
+		return withKind(ErrMissingConfig, fmt.Errorf(msgRerunConfigure, BinaryName))
 	}
 	return nil
 }
 
+// userWorkspace is the workspace from the user config, with a leading ~ and
+// any environment variables expanded, and any stray whitespace trimmed.
+func userWorkspace(cfg *viper.Viper) string {
+	return config.Expand(strings.TrimSpace(cfg.GetString("workspace")))
+}
+
 // decodedAPIError decodes and returns the error message from the API response.
 // If the message is blank, it returns a fallback message with the status code.
+// A 401 response gives an ErrUnauthorized error, explaining how to fix the
+// token if the API didn't say what was wrong, as when a proxy answers with
+// an HTML page. A 404 response gives an ErrNotFound error, unless it's
+// because the request was ambiguous.
 func decodedAPIError(resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		err := decodeAPIError(resp)
+		var ambiguous *ambiguousError
+		if errors.As(err, &ambiguous) {
+			return err
+		}
+		return withKind(ErrNotFound, err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return decodeAPIError(resp)
+	}
+	err := decodeAPIError(resp)
+	var unexplained *unexplainedAPIError
+	if errors.As(err, &unexplained) {
+		err = fmt.Errorf(msgUnauthorized, BinaryName)
+	}
+	return withKind(ErrUnauthorized, err)
+}
+
+// unexplainedAPIError is an API error response without a message saying what went wrong.
+type unexplainedAPIError struct {
+	message string
+}
+
+func (e *unexplainedAPIError) Error() string {
+	return e.message
+}
+
+func decodeAPIError(resp *http.Response) error {
 	var apiError struct {
 		Error struct {
 			Type             string   `json:"type"`
 			Message          string   `json:"message"`
 			PossibleTrackIDs []string `json:"possible_track_ids"`
+			PossibleTeamIDs  []string `json:"possible_team_ids"`
 		} `json:"error,omitempty"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&apiError); err != nil {
-		return fmt.Errorf("failed to parse API error response: %s", err)
+		return &unexplainedAPIError{message: fmt.Sprintf("failed to parse API error response: %s", err)}
 	}
 	if apiError.Error.Message != "" {
-		if apiError.Error.Type == "track_ambiguous" {
-			return fmt.Errorf(
-				"%s: %s",
-				apiError.Error.Message,
-				strings.Join(apiError.Error.PossibleTrackIDs, ", "),
-			)
+		switch apiError.Error.Type {
+		case "track_ambiguous":
+			return &ambiguousError{
+				message: apiError.Error.Message,
+				flag:    "track",
+				ids:     apiError.Error.PossibleTrackIDs,
+			}
+		case "team_ambiguous":
+			return &ambiguousError{
+				message: apiError.Error.Message,
+				flag:    "team",
+				ids:     apiError.Error.PossibleTeamIDs,
+			}
 		}
 		return fmt.Errorf(apiError.Error.Message)
 	}
-	return fmt.Errorf("unexpected API response: %d", resp.StatusCode)
+	return &unexplainedAPIError{message: fmt.Sprintf("unexpected API response: %d", resp.StatusCode)}
+}
+
+// ambiguousError lists the tracks or teams that a request might refer to.
+// When the exercise slug is known, it shows the command to download
+// the exercise with each of them instead.
+type ambiguousError struct {
+	message string
+	// flag is the option that needs to be given, either track or team.
+	flag string
+	ids  []string
+	// The options that were given, to repeat in the suggested commands.
+	slug, track, team string
+}
+
+func (e *ambiguousError) Is(target error) bool {
+	if e.flag == "team" {
+		return target == ErrTeamAmbiguous
+	}
+	return target == ErrTrackAmbiguous
+}
+
+func (e *ambiguousError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.message)
+	if e.slug == "" {
+		b.WriteString(":\n")
+		for _, id := range e.ids {
+			fmt.Fprintf(&b, "\n    %s", id)
+		}
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n\nTo download it, re-run the command with one of the %ss:\n", e.flag)
+	for _, id := range e.ids {
+		track, team := e.track, e.team
+		if e.flag == "track" {
+			track = id
+		} else {
+			team = id
+		}
+		fmt.Fprintf(&b, "\n    %s download --exercise=%s", BinaryName, e.slug)
+		if track != "" {
+			fmt.Fprintf(&b, " --track=%s", track)
+		}
+		if team != "" {
+			fmt.Fprintf(&b, " --team=%s", team)
+		}
+	}
+	return b.String()
+}
+
+const (
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// isTerminal reports whether the writer is attached to a terminal.
+var isTerminal = func(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps the message in the color, if the writer displays colors.
+func colorize(w io.Writer, color, msg string) string {
+	if noColor || os.Getenv("NO_COLOR") != "" || !isTerminal(w) {
+		return msg
+	}
+	return color + msg + colorReset
+}
+
+// interruptContext returns a context that is canceled when the process is interrupted
+// or terminated, so that a command can stop its requests and clean up before it exits.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(interrupts)
+		select {
+		case <-interrupts:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
 }
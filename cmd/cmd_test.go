@@ -1,17 +1,25 @@
 package cmd
 
 import (
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
 	"testing"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
 const cfgHomeKey = "EXERCISM_CONFIG_HOME"
 
+func TestMain(m *testing.M) {
+	// Keep tests from sharing solution payloads through the user's cache.
+	payloadCacheDir = func() string { return "" }
+	os.Exit(m.Run())
+}
+
 // CommandTest makes it easier to write tests for Cobra commands.
 //
 // To initialize, give it the three fields Cmd, InitFn, and Args.
@@ -118,3 +126,39 @@ func (co capturedOutput) reset() {
 	Out = co.oldOut
 	Err = co.oldErr
 }
+
+func TestValidateUserConfigToken(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		token string
+		err   string
+	}{
+		{desc: "valid", token: "a1b2c3d4-e5f6-7890-abcd-ef1234567890"},
+		{desc: "padded with whitespace", token: "  abc123\n"},
+		{desc: "quoted", token: `"abc123"`, err: "doesn't look right"},
+		{desc: "with inner spaces", token: "abc 123", err: "doesn't look right"},
+		{desc: "with a prefix", token: "Bearer abc123", err: "doesn't look right"},
+		{desc: "only whitespace", token: " \n", err: "configure the tool with your API token"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			v := viper.New()
+			v.Set("token", tc.token)
+			v.Set("workspace", "/tmp")
+			v.Set("apibaseurl", "http://example.com")
+
+			err := validateUserConfig(v)
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.err)
+					assert.True(t, errors.Is(err, ErrMissingConfig))
+				}
+				return
+			}
+			assert.NoError(t, err)
+			// Validating leaves the config alone.
+			assert.Equal(t, tc.token, v.GetString("token"))
+		})
+	}
+}
@@ -57,6 +57,26 @@ func runConfigure(configuration config.Config, flags *pflag.FlagSet) error {
 		return nil
 	}
 
+	// Print where to find the token and exit, which doesn't need a token yet.
+	printTokenURL, err := flags.GetBool("print-token-url")
+	if err != nil {
+		return err
+	}
+	if printTokenURL {
+		baseURL, err := flags.GetString("api")
+		if err != nil {
+			return err
+		}
+		if baseURL == "" {
+			baseURL = cfg.GetString("apibaseurl")
+		}
+		if baseURL == "" {
+			baseURL = configuration.DefaultBaseURL
+		}
+		fmt.Fprintln(Out, config.SettingsURL(baseURL))
+		return nil
+	}
+
 	// If the command is run 'bare' and we have no token,
 	// explain how to set the token.
 	if flags.NFlag() == 0 && cfg.GetString("token") == "" {
@@ -231,6 +251,7 @@ func setupConfigureFlags(flags *pflag.FlagSet) {
 	flags.StringP("workspace", "w", "", "directory for exercism exercises")
 	flags.StringP("api", "a", "", "API base url")
 	flags.BoolP("show", "s", false, "show the current configuration")
+	flags.BoolP("print-token-url", "", false, "print the URL of the page showing your token")
 	flags.BoolP("no-verify", "", false, "skip online token authorization check")
 }
 
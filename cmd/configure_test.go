@@ -79,6 +79,62 @@ func TestConfigureShow(t *testing.T) {
 	assert.NotRegexp(t, "workspace-override", Err)
 }
 
+func TestConfigurePrintTokenURL(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		args       []string
+		apibaseurl string
+		expected   string
+	}{
+		{
+			desc:     "default base URL",
+			args:     []string{"--print-token-url"},
+			expected: "https://exercism.io/my/settings\n",
+		},
+		{
+			desc:       "configured base URL",
+			args:       []string{"--print-token-url"},
+			apibaseurl: "http://configured.example.com/v1",
+			expected:   "http://configured.example.com/my/settings\n",
+		},
+		{
+			desc:       "base URL flag",
+			args:       []string{"--print-token-url", "--api", "http://override.example.com/v1"},
+			apibaseurl: "http://configured.example.com/v1",
+			expected:   "http://override.example.com/my/settings\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupConfigureFlags(flags)
+			err := flags.Parse(tc.args)
+			assert.NoError(t, err)
+
+			// There is no token.
+			v := viper.New()
+			if tc.apibaseurl != "" {
+				v.Set("apibaseurl", tc.apibaseurl)
+			}
+			cfg := config.Config{
+				Persister:       config.InMemoryPersister{},
+				UserViperConfig: v,
+				DefaultBaseURL:  "https://api.exercism.io/v1",
+			}
+
+			err = runConfigure(cfg, flags)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, Out.(*bytes.Buffer).String())
+		})
+	}
+}
+
 func TestConfigureToken(t *testing.T) {
 	co := newCapturedOutput()
 	co.override()
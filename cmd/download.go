@@ -1,22 +1,43 @@
 package cmd
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	netURL "net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/exercism/cli/api"
 	"github.com/exercism/cli/config"
+	"github.com/exercism/cli/debug"
 	"github.com/exercism/cli/workspace"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -33,191 +54,2577 @@ You may download an exercise to work on. If you've already
 started working on it, the command will also download your
 latest solution.
 
-Download other people's solutions by providing the UUID.
+Download other people's solutions by providing the UUID,
+or by pasting the solution's URL from the website.
+
+The token, workspace, and API base URL can be given with the
+EXERCISM_TOKEN, EXERCISM_WORKSPACE, and EXERCISM_API_BASE_URL
+environment variables, which take precedence over the user config.
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg := config.NewConfig()
 
-		v := viper.New()
-		v.AddConfigPath(cfg.Dir)
-		v.SetConfigName("user")
-		v.SetConfigType("json")
-		// Ignore error. If the file doesn't exist, that is fine.
-		_ = v.ReadInConfig()
+		v, err := downloadUserConfig(cfg.Dir, cmd.Flags())
+		if err != nil {
+			return err
+		}
 		cfg.UserViperConfig = v
 
-		return runDownload(cfg, cmd.Flags(), args)
+		// Errors are printed as JSON by runDownload.
+		asJSON, _ := cmd.Flags().GetBool("json")
+		events, _ := cmd.Flags().GetBool("events")
+		if asJSON || events {
+			cmd.SilenceErrors = true
+		}
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		return runDownload(ctx, cfg, cmd.Flags(), args)
 	},
 }
 
-func runDownload(cfg config.Config, flags *pflag.FlagSet, args []string) error {
-	usrCfg := cfg.UserViperConfig
-	if err := validateUserConfig(usrCfg); err != nil {
-		return err
+// userConfigEnv names the environment variables that can stand in for
+// the settings of the user config, so that no config file is needed.
+var userConfigEnv = map[string]string{
+	"token":      "EXERCISM_TOKEN",
+	"workspace":  "EXERCISM_WORKSPACE",
+	"apibaseurl": "EXERCISM_API_BASE_URL",
+}
+
+// downloadUserConfig reads the user config from the config dir,
+// or from the file given with --config.
+// Settings given in the environment take precedence over the file.
+func downloadUserConfig(dir string, flags *pflag.FlagSet) (*viper.Viper, error) {
+	v := viper.New()
+	for key, env := range userConfigEnv {
+		if err := v.BindEnv(key, env); err != nil {
+			return nil, err
+		}
+	}
+
+	path, _ := flags.GetString("config")
+	if path != "" {
+		v.SetConfigFile(config.Expand(path))
+		v.SetConfigType("json")
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("unable to read config file '%s': %s", path, err)
+		}
+		return v, nil
 	}
 
-	download, err := newDownload(flags, usrCfg)
+	v.AddConfigPath(dir)
+	v.SetConfigName("user")
+	v.SetConfigType("json")
+	// Ignore error. If the file doesn't exist, that is fine.
+	_ = v.ReadInConfig()
+	return v, nil
+}
+
+// loadUserConfig reads the user config the way the download command does,
+// for the commands that share its settings: from the --config file or the
+// config dir, with the environment and the --profile applied over it, and
+// the token given by --token, if any.
+func loadUserConfig(dir string, flags *pflag.FlagSet) (*viper.Viper, error) {
+	v, err := downloadUserConfig(dir, flags)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	profile, _ := flags.GetString("profile")
+	if v, err = profileUserConfig(v, profile); err != nil {
+		return nil, err
+	}
+	token, _ := flags.GetString("token")
+	return tokenUserConfig(v, strings.TrimSpace(token)), nil
+}
+
+// profileUserConfig overlays the named profile's settings on the user config.
+// Profiles live under "profiles" in the user config, and anything a profile
+// doesn't set falls back to the top-level value.
+func profileUserConfig(usrCfg *viper.Viper, name string) (*viper.Viper, error) {
+	if name == "" {
+		return usrCfg, nil
+	}
+	profile := usrCfg.Sub("profiles." + name)
+	if profile == nil {
+		return nil, fmt.Errorf("there is no profile named '%s' in the user config", name)
 	}
 
-	metadata := download.payload.metadata()
-	dir := metadata.Exercise(usrCfg.GetString("workspace")).MetadataDir()
+	v := viper.New()
+	for _, key := range usrCfg.AllKeys() {
+		v.Set(key, usrCfg.Get(key))
+	}
+	for _, key := range profile.AllKeys() {
+		v.Set(key, profile.Get(key))
+	}
+	return v, nil
+}
 
-	if _, err = os.Stat(dir); !download.forceoverwrite && err == nil {
-		return fmt.Errorf("directory '%s' already exists, use --force to overwrite", dir)
+// tokenUserConfig overrides the token of the user config with the one given by --token,
+// without changing the user config itself.
+func tokenUserConfig(usrCfg *viper.Viper, token string) *viper.Viper {
+	if token == "" {
+		return usrCfg
+	}
+	v := viper.New()
+	for _, key := range usrCfg.AllKeys() {
+		v.Set(key, usrCfg.Get(key))
 	}
+	v.Set("token", token)
+	return v
+}
 
-	if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+func runDownload(ctx context.Context, cfg config.Config, flags *pflag.FlagSet, args []string) error {
+	asJSON, _ := flags.GetBool("json")
+	var events *downloadEvents
+	if withEvents, _ := flags.GetBool("events"); withEvents {
+		events = &downloadEvents{w: Out}
+	}
+
+	var summary DownloadResult
+	var err error
+	profile, _ := flags.GetString("profile")
+	cfg.UserViperConfig, err = profileUserConfig(cfg.UserViperConfig, profile)
+	if err == nil {
+		token, _ := flags.GetString("token")
+		cfg.UserViperConfig = tokenUserConfig(cfg.UserViperConfig, token)
+		summary, err = downloadSolution(ctx, cfg, flags)
+	}
+	if err != nil {
+		err = redactToken(err, cfg.UserViperConfig.GetString("token"))
+		if asJSON {
+			json.NewEncoder(Err).Encode(map[string]string{"error": err.Error()})
+		}
+		events.emit("error", map[string]interface{}{"error": err.Error()})
 		return err
 	}
+	if summary.Destination == "" {
+		return nil
+	}
 
-	if err := metadata.Write(dir); err != nil {
+	if events != nil {
+		events.emit("done", map[string]interface{}{
+			"destination": summary.Destination,
+			"files":       len(summary.Files),
+			"bytes":       summary.Bytes,
+		})
+		return nil
+	}
+	if asJSON {
+		return json.NewEncoder(Out).Encode(summary)
+	}
+	if printDestination, _ := flags.GetBool("print-destination"); printDestination {
+		return printAbsolutePath(summary.Destination)
+	}
+	if summary.UpToDate {
+		if !quiet {
+			fmt.Fprintf(Out, "Already up to date\n")
+		}
+		return nil
+	}
+	if !quiet {
+		fmt.Fprintf(Out, "%s\n", summary)
+	}
+	fmt.Fprintf(Err, "\nDownloaded to\n")
+	fmt.Fprintf(Out, "%s\n", summary.Destination)
+	return nil
+}
+
+// redactedError hides the API token in an error message,
+// since error output tends to get pasted into public issues.
+type redactedError struct {
+	err   error
+	token string
+}
+
+func (e redactedError) Error() string {
+	return strings.Replace(e.err.Error(), e.token, "[REDACTED]", -1)
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactToken scrubs the token from the error, if it mentions it.
+func redactToken(err error, token string) error {
+	token = strings.TrimSpace(token)
+	if err == nil || token == "" || !strings.Contains(err.Error(), token) {
+		return err
+	}
+	return redactedError{err: err, token: token}
+}
+
+// DownloadResult describes a completed download.
+type DownloadResult struct {
+	ID          string        `json:"id"`
+	Track       string        `json:"track"`
+	Exercise    string        `json:"exercise"`
+	Destination string        `json:"destination"`
+	Files       []string      `json:"files"`
+	Bytes       int64         `json:"bytes"`
+	Elapsed     time.Duration `json:"-"`
+	// UpToDate is set when the files were already there, so nothing was written.
+	UpToDate bool `json:"up_to_date"`
+}
+
+// String reports how much was downloaded, and how long it took.
+func (s DownloadResult) String() string {
+	elapsed := s.Elapsed.Round(time.Millisecond)
+	if s.Elapsed >= time.Second {
+		elapsed = s.Elapsed.Round(100 * time.Millisecond)
+	}
+	return fmt.Sprintf("Downloaded %d files (%s) in %s", len(s.Files), formatBytes(s.Bytes), elapsed)
+}
+
+// formatBytes describes a size in bytes in the largest unit that fits it.
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	size := float64(n) / 1024
+	units := []string{"KB", "MB", "GB"}
+	unit := 0
+	for size >= 1024 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if size < 10 {
+		return fmt.Sprintf("%.1f %s", size, units[unit])
+	}
+	return fmt.Sprintf("%.0f %s", size, units[unit])
+}
+
+// downloadSolution downloads the solution picked by the download command's flags.
+// It returns an empty result if nothing was written.
+func downloadSolution(ctx context.Context, cfg config.Config, flags *pflag.FlagSet) (DownloadResult, error) {
+	start := time.Now()
+	usrCfg := cfg.UserViperConfig
+	// A captured payload doesn't need to talk to the API.
+	if fromFile, _ := flags.GetString("from-file"); fromFile == "" {
+		if err := validateUserConfig(usrCfg); err != nil {
+			return DownloadResult{}, err
+		}
+	}
+
+	d, err := newDownload(ctx, flags, usrCfg)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+	if d.compareUUID != "" {
+		return DownloadResult{}, d.compareWith(ctx, flags, usrCfg)
+	}
+	return d.save(ctx, start)
+}
+
+// compareWith prints a unified diff of each file that differs between the
+// solution and the one given by --compare-uuid. Nothing is written to disk.
+func (d *download) compareWith(ctx context.Context, flags *pflag.FlagSet, usrCfg *viper.Viper) error {
+	compareFlags := downloadFlagSet(flags)
+	compareFlags.Set("uuid", d.compareUUID)
+	compareFlags.Set("compare-uuid", "")
+	other, err := newDownload(ctx, compareFlags, usrCfg)
+	if err != nil {
 		return err
 	}
 
-	client, err := api.NewClient(usrCfg.GetString("token"), usrCfg.GetString("apibaseurl"))
+	before, err := d.readSolutionFiles(ctx)
+	if err != nil {
+		return err
+	}
+	after, err := other.readSolutionFiles(ctx)
 	if err != nil {
 		return err
 	}
 
-	for _, sf := range download.payload.files() {
-		url, err := sf.url()
-		if err != nil {
-			return err
+	names := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
 		}
+	}
+	sort.Strings(names)
 
-		req, err := client.NewRequest("GET", url, nil)
-		if err != nil {
-			return err
+	differ := false
+	for _, name := range names {
+		a, inBefore := before[name]
+		b, inAfter := after[name]
+		if inBefore && inAfter && bytes.Equal(a, b) {
+			continue
 		}
+		differ = true
 
-		res, err := client.Do(req)
+		// A file that only one of the solutions has is compared with nothing.
+		fromFile, toFile := d.uuid+"/"+name, other.uuid+"/"+name
+		if !inBefore {
+			fromFile = os.DevNull
+		}
+		if !inAfter {
+			toFile = os.DevNull
+		}
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        diffLines(a),
+			B:        diffLines(b),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
 		if err != nil {
 			return err
 		}
-		defer res.Body.Close()
+		fmt.Fprint(Out, diff)
+	}
+	if !differ {
+		fmt.Fprintf(Err, "\nThe solutions' files are the same\n")
+	}
+	return nil
+}
 
-		if res.StatusCode != http.StatusOK {
-			// TODO: deal with it
-			continue
+// diffLines splits the contents into lines for a diff. Unlike difflib.SplitLines,
+// it doesn't add an empty line at the end, nor a line to an empty file.
+func diffLines(contents []byte) []string {
+	if len(contents) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(contents), "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
+
+// readSolutionFiles downloads the contents of the solution's files, by their
+// slash-separated paths. Files that the server wouldn't serve are left out.
+func (d *download) readSolutionFiles(ctx context.Context) (map[string][]byte, error) {
+	files := map[string][]byte{}
+	for _, sf := range d.solutionFiles() {
+		res, err := d.requestFile(ctx, sf, 0, "")
+		if err != nil {
+			return nil, err
 		}
-		// Don't bother with empty files.
-		if res.Header.Get("Content-Length") == "0" {
+		if res == nil {
 			continue
 		}
+		b, err := ioutil.ReadAll(res.body)
+		res.body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unable to download '%s': %w", sf.path, err)
+		}
+		files[filepath.ToSlash(sf.relativePath())] = b
+	}
+	return files, nil
+}
 
-		path := sf.relativePath()
-		dir := filepath.Join(metadata.Dir, filepath.Dir(path))
-		if err = os.MkdirAll(dir, os.FileMode(0755)); err != nil {
-			return err
+// save writes the solution to its destination, and runs the post-download hook.
+// It returns an empty summary if nothing was written. If the download is
+// interrupted, the files it created are removed again.
+func (d *download) save(ctx context.Context, start time.Time) (summary DownloadResult, err error) {
+	if d.listFilesOnly {
+		return DownloadResult{}, d.printFiles()
+	}
+	if d.stdout != "" {
+		return DownloadResult{}, d.printFile(ctx)
+	}
+
+	metadata := d.metadata()
+	dir := d.destination()
+
+	if d.verifyOnly {
+		return DownloadResult{}, d.verifyFiles(ctx, dir)
+	}
+
+	if _, err := os.Stat(dir); !d.allowsExistingDestination() && err == nil {
+		// Downloading the same solution again is fine when there's nothing to change.
+		upToDate := false
+		if !d.dryRun {
+			if upToDate, err = d.isUpToDate(ctx, dir); err != nil {
+				return DownloadResult{}, err
+			}
+		}
+		if !upToDate {
+			return DownloadResult{}, fmt.Errorf("directory '%s' already exists, use --force to overwrite", dir)
+		}
+		return DownloadResult{
+			ID:          metadata.ID,
+			Track:       metadata.Track,
+			Exercise:    metadata.ExerciseSlug,
+			Destination: dir,
+			Files:       []string{},
+			Elapsed:     time.Since(start),
+			UpToDate:    true,
+		}, nil
+	}
+
+	if d.dryRun {
+		if d.printDestination {
+			return DownloadResult{}, printAbsolutePath(dir)
+		}
+		d.printDryRun(dir)
+		return DownloadResult{}, nil
+	}
+
+	if !metadata.IsRequester && !d.quiet && !d.asJSON && d.events == nil {
+		fmt.Fprintf(Out, "Downloading %s's solution to %s (read-only)\n", metadata.Handle, dir)
+	}
+
+	// A solution that hasn't been submitted yet has nothing to download,
+	// which would otherwise leave a puzzling empty directory.
+	if len(d.solutionFiles()) == 0 {
+		if d.strict {
+			return DownloadResult{}, errors.New("the solution has no files to download")
+		}
+		if !d.quiet && !d.asJSON && d.events == nil {
+			fmt.Fprintf(Out, "Solution has no files to download\n")
+		}
+	}
+
+	d.created = &createdFiles{}
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			d.created.remove()
+		}
+	}()
+	d.created.addIfMissing(outermostMissingPath(dir))
+	if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+		return DownloadResult{}, err
+	}
+
+	d.created.addIfMissing(outermostMissingPath(workspace.NewExerciseFromDir(dir).MetadataFilepath()))
+	if err := metadata.Write(dir); err != nil {
+		return DownloadResult{}, err
+	}
+
+	written, err := d.writeSolutionFiles(ctx, metadata.Dir)
+	if err != nil {
+		return DownloadResult{}, err
+	}
+
+	// The checksums of the files as written let local changes be told apart later.
+	if metadata.SHA256, err = fileSums(metadata.Dir, written); err != nil {
+		return DownloadResult{}, err
+	}
+	if err := metadata.Write(metadata.Dir); err != nil {
+		return DownloadResult{}, err
+	}
+
+	if d.withInstructions {
+		readme, err := d.writeInstructions(ctx, metadata.Dir)
+		if err != nil {
+			return DownloadResult{}, err
+		}
+		if readme != "" {
+			written = append(written, readme)
 		}
+	}
 
-		f, err := os.Create(filepath.Join(metadata.Dir, path))
+	if d.writeURL {
+		path, err := d.writeSolutionURL(metadata.Dir)
 		if err != nil {
+			return DownloadResult{}, err
+		}
+		if path != "" {
+			written = append(written, path)
+		}
+	}
+
+	if d.gitCommit {
+		if err := d.commitToGit(ctx, metadata, written); err != nil {
+			warnf("The download wasn't committed: %s.", err)
+		}
+	}
+
+	if d.hook != "" {
+		if err := d.runHook(ctx, metadata.Dir); err != nil {
+			warnf("The post-download hook failed: %s. The downloaded files were kept.", err)
+		}
+	}
+
+	return DownloadResult{
+		ID:          metadata.ID,
+		Track:       metadata.Track,
+		Exercise:    metadata.ExerciseSlug,
+		Destination: metadata.Dir,
+		Files:       written,
+		Bytes:       atomic.LoadInt64(&d.bytesWritten),
+		Elapsed:     time.Since(start),
+	}, nil
+}
+
+// writeInstructions downloads the exercise instructions into dir as a README.
+// It returns the path of the README, or an empty path if there was nothing to write.
+func (d *download) writeInstructions(ctx context.Context, dir string) (string, error) {
+	url := d.payload.Solution.Exercise.InstructionsURL
+	if url == "" {
+		return "", nil
+	}
+	for _, sf := range d.payload.files() {
+		if strings.EqualFold(sf.relativePath(), instructionsFilename) {
+			return "", nil
+		}
+	}
+
+	req, err := d.client.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to download the instructions: %w", err)
+	}
+	res, err := d.do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to download the instructions: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		warnf("Skipping the instructions, the server responded with %s.", res.Status)
+		return "", nil
+	}
+
+	path := filepath.Join(dir, instructionsFilename)
+	d.created.addIfMissing(path)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	n, err := io.Copy(f, res.Body)
+	atomic.AddInt64(&d.bytesWritten, n)
+	if err != nil {
+		return "", err
+	}
+	d.events.emit("file", map[string]interface{}{"name": instructionsFilename, "path": path, "bytes": n})
+	return path, nil
+}
+
+// writeSolutionURL writes the solution's URL on the website into dir, to be
+// opened from there. It returns the path of the file, or an empty path if
+// there was nothing to write.
+func (d *download) writeSolutionURL(dir string) (string, error) {
+	url := d.payload.Solution.URL
+	if url == "" {
+		return "", nil
+	}
+	for _, sf := range d.payload.files() {
+		if strings.EqualFold(sf.relativePath(), solutionURLFilename) {
+			return "", nil
+		}
+	}
+
+	path := filepath.Join(dir, solutionURLFilename)
+	d.created.addIfMissing(path)
+	contents := url + "\n"
+	if err := ioutil.WriteFile(path, []byte(contents), os.FileMode(0644)); err != nil {
+		return "", err
+	}
+	atomic.AddInt64(&d.bytesWritten, int64(len(contents)))
+	d.events.emit("file", map[string]interface{}{"name": solutionURLFilename, "path": path, "bytes": len(contents)})
+	return path, nil
+}
+
+// commitToGit commits the metadata and the written files to the git repository
+// that the exercise is in. Nothing is committed if they haven't changed.
+func (d *download) commitToGit(ctx context.Context, metadata workspace.ExerciseMetadata, written []string) error {
+	git := func(args ...string) (string, error) {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = metadata.Dir
+		out, err := cmd.CombinedOutput()
+		if err != nil && len(bytes.TrimSpace(out)) > 0 {
+			err = fmt.Errorf("git %s: %s", args[0], bytes.TrimSpace(out))
+		}
+		return strings.TrimSpace(string(out)), err
+	}
+
+	if out, err := git("rev-parse", "--is-inside-work-tree"); err != nil || out != "true" {
+		return fmt.Errorf("'%s' isn't in a git repository", metadata.Dir)
+	}
+
+	paths := append([]string{workspace.NewExerciseFromDir(metadata.Dir).MetadataFilepath()}, written...)
+	if _, err := git(append([]string{"add", "--"}, paths...)...); err != nil {
+		return err
+	}
+	if _, err := git(append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		return nil
+	}
+	msg := fmt.Sprintf("Download %s/%s", metadata.Track, metadata.ExerciseSlug)
+	_, err := git(append([]string{"commit", "--quiet", "--message", msg, "--"}, paths...)...)
+	return err
+}
+
+// runHook runs the post-download hook in dir, through the shell.
+// The hook's output goes to Err, to keep Out for the destination.
+func (d *download) runHook(ctx context.Context, dir string) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", d.hook)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", d.hook)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), hookDirEnv+"="+dir)
+	cmd.Stdout = Err
+	cmd.Stderr = Err
+	return cmd.Run()
+}
+
+// printFiles lists the solution's files without downloading them.
+func (d *download) printFiles() error {
+	solutionFiles := d.payload.files()
+	files := make([]string, 0, len(solutionFiles))
+	for _, sf := range solutionFiles {
+		files = append(files, sf.relativePath())
+	}
+
+	if d.asJSON {
+		return json.NewEncoder(Out).Encode(files)
+	}
+	for _, file := range files {
+		fmt.Fprintf(Out, "%s\n", file)
+	}
+	return nil
+}
+
+// printFile writes the contents of the solution file named by --stdout to Out,
+// without writing anything to disk.
+func (d *download) printFile(ctx context.Context) error {
+	name := filepath.ToSlash(filepath.Clean(d.stdout))
+	for _, sf := range d.payload.files() {
+		if filepath.ToSlash(sf.relativePath()) != name {
+			continue
+		}
+		res, err := d.requestFile(ctx, sf, 0, "")
+		if err != nil || res == nil {
 			return err
 		}
-		defer f.Close()
-		_, err = io.Copy(f, res.Body)
+		defer res.body.Close()
+
+		_, err = io.Copy(Out, res.body)
+		return err
+	}
+	return fmt.Errorf("'%s' is not one of the solution's files", d.stdout)
+}
+
+// verifyFiles compares the solution's files with the ones in dir, reporting
+// which match, which differ, and which are missing. Nothing is written.
+func (d *download) verifyFiles(ctx context.Context, dir string) error {
+	files := d.payload.files()
+	mismatched := 0
+	for _, sf := range files {
+		status, err := d.verifyFile(ctx, sf, dir)
 		if err != nil {
 			return err
 		}
+		if status != "match" {
+			mismatched++
+		}
+		fmt.Fprintf(Out, "%-8s%s\n", status, d.writePath(sf))
+	}
+	if mismatched > 0 {
+		return fmt.Errorf("%d of %d files don't match the solution in '%s'", mismatched, len(files), dir)
 	}
-	fmt.Fprintf(Err, "\nDownloaded to\n")
-	fmt.Fprintf(Out, "%s\n", metadata.Dir)
 	return nil
 }
 
-type download struct {
-	// either/or
-	slug, uuid string
+// verifyFile tells whether the local copy of the file matches the solution,
+// differs from it, or is missing.
+func (d *download) verifyFile(ctx context.Context, sf solutionFile, dir string) (string, error) {
+	target := filepath.Join(dir, d.writePath(sf))
+	if !isWithinDir(dir, target) {
+		return "", fmt.Errorf("refusing to read '%s' outside of '%s'", sf.path, dir)
+	}
+	local, err := ioutil.ReadFile(target)
+	if os.IsNotExist(err) {
+		return "missing", nil
+	}
+	if err != nil {
+		return "", err
+	}
 
-	// user config
-	token, apibaseurl, workspace string
+	res, err := d.requestFile(ctx, sf, 0, "")
+	if err != nil {
+		return "", err
+	}
+	var remote []byte
+	if res != nil {
+		defer res.body.Close()
+		if remote, err = ioutil.ReadAll(res.body); err != nil {
+			return "", fmt.Errorf("unable to download '%s': %w", sf.path, err)
+		}
+	}
+	if !bytes.Equal(local, remote) {
+		return "differs", nil
+	}
+	return "match", nil
+}
 
-	// optional
-	track, team    string
-	forceoverwrite bool
+// isUpToDate tells whether every one of the solution's files is already in dir,
+// just as it is on the server.
+func (d *download) isUpToDate(ctx context.Context, dir string) (bool, error) {
+	for _, sf := range d.solutionFiles() {
+		status, err := d.verifyFile(ctx, sf, dir)
+		if err != nil || status != "match" {
+			return false, err
+		}
+	}
+	return true, nil
+}
 
-	payload *downloadPayload
+// printAbsolutePath writes the absolute path to Out, for shell substitution.
+func printAbsolutePath(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Out, "%s\n", path)
+	return nil
 }
 
-func newDownload(flags *pflag.FlagSet, usrCfg *viper.Viper) (*download, error) {
-	var err error
-	d := &download{}
-	d.uuid, err = flags.GetString("uuid")
+// printDryRun lists where the metadata and solution files would be written.
+func (d *download) printDryRun(dir string) {
+	fmt.Fprintf(Err, "\nWould download to\n")
+	fmt.Fprintf(Out, "%s\n", workspace.NewExerciseFromDir(dir).MetadataFilepath())
+	for _, sf := range d.solutionFiles() {
+		fmt.Fprintf(Out, "%s\n", filepath.Join(dir, d.writePath(sf)))
+	}
+}
+
+// writeSolutionFiles downloads the solution files into dir, returning the
+// absolute paths of the files that were written, in the solution's order.
+// Skipped files, such as empty ones or those the server wouldn't serve, are left out.
+// Up to d.concurrency files are fetched at a time. The first failure cancels
+// the remaining requests, and the reported error is that of the earliest
+// failing file in the solution's file list. Canceling the context stops the
+// download, and its error is returned.
+func (d *download) writeSolutionFiles(parent context.Context, dir string) ([]string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	resolver := &collisionResolver{
+		interactive: stdinIsTerminal() && d.collisions != collisionsSkip,
+		suffix:      d.collisions == collisionsSuffix,
+		in:          bufio.NewReader(In),
+	}
+
+	files := uniqueFiles(d.solutionFiles())
+	if d.resume {
+		files = d.missingFiles(files, dir)
+	}
+	paths := make([]string, len(files))
+	errs := make([]error, len(files))
+	jobs := make(chan int)
+	progress := &downloadProgress{total: len(files), quiet: d.quiet || d.asJSON || d.events != nil}
+	d.events.emit("start", map[string]interface{}{"files": len(files)})
+
+	var wg sync.WaitGroup
+	for i := 0; i < d.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				path, err := d.writeSolutionFile(ctx, resolver, files[j], dir)
+				if err != nil {
+					errs[j] = err
+					cancel()
+					continue
+				}
+				paths[j] = path
+				progress.increment()
+			}
+		}()
+	}
+
+enqueue:
+	for i := range files {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break enqueue
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var canceled error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if canceled == nil {
+			canceled = err
+		}
+	}
+	if canceled != nil {
+		return nil, canceled
+	}
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+
+	written := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path != "" {
+			written = append(written, path)
+		}
+	}
+	return written, nil
+}
+
+// solutionFiles gives the solution's files to download, which is all of them
+// unless --file picked some.
+func (d *download) solutionFiles() []solutionFile {
+	files := d.payload.files()
+	if len(d.onlyFiles) == 0 {
+		return files
+	}
+	picked := make([]solutionFile, 0, len(d.onlyFiles))
+	for _, sf := range files {
+		if d.picksFile(sf) {
+			picked = append(picked, sf)
+		}
+	}
+	return picked
+}
+
+// picksFile tells whether the file is one of those named by --file.
+func (d *download) picksFile(sf solutionFile) bool {
+	for _, only := range d.onlyFiles {
+		if sf.isNamed(only) {
+			return true
+		}
+	}
+	return false
+}
+
+// writePath is the path, relative to the exercise directory, that the file is
+// written to. A file whose extension is mapped by --rename takes the new one.
+func (d *download) writePath(sf solutionFile) string {
+	path := sf.relativePath()
+	ext := filepath.Ext(path)
+	if to, ok := d.renames[ext]; ok && ext != "" {
+		return strings.TrimSuffix(path, ext) + to
+	}
+	return path
+}
+
+// renamed maps the paths of the files that --rename writes under another
+// name to the paths they are written to.
+func (d *download) renamed() map[string]string {
+	renamed := map[string]string{}
+	for _, sf := range d.solutionFiles() {
+		if path := d.writePath(sf); path != sf.relativePath() {
+			renamed[filepath.ToSlash(sf.relativePath())] = filepath.ToSlash(path)
+		}
+	}
+	if len(renamed) == 0 {
+		return nil
+	}
+	return renamed
+}
+
+// uniqueFiles drops the files that are listed more than once, which would
+// otherwise be downloaded again, to the same place.
+func uniqueFiles(files []solutionFile) []solutionFile {
+	seen := make(map[string]bool, len(files))
+	unique := make([]solutionFile, 0, len(files))
+	for _, sf := range files {
+		path := sf.relativePath()
+		if seen[path] {
+			warnf("The solution lists '%s' more than once, it is only downloaded once.", path)
+			continue
+		}
+		seen[path] = true
+		unique = append(unique, sf)
+	}
+	return unique
+}
+
+// missingFiles filters out the files that have already been written to dir,
+// reporting how much of a partial download was already complete.
+func (d *download) missingFiles(files []solutionFile, dir string) []solutionFile {
+	missing := make([]solutionFile, 0, len(files))
+	for _, sf := range files {
+		if _, err := os.Lstat(filepath.Join(dir, d.writePath(sf))); err != nil {
+			missing = append(missing, sf)
+		}
+	}
+	if present := len(files) - len(missing); present > 0 {
+		fmt.Fprintf(Err, "\nResuming download, %d of %d files already present\n", present, len(files))
+	}
+	return missing
+}
+
+// writeSolutionFile downloads a single solution file into dir.
+// It returns the path of the written file, or an empty path if the file was skipped.
+// A file that takes longer than the per-file timeout is skipped, unless
+// every file is required.
+func (d *download) writeSolutionFile(ctx context.Context, resolver *collisionResolver, sf solutionFile, dir string) (string, error) {
+	if d.timeoutPerFile <= 0 {
+		return d.writeSolutionFileWithin(ctx, resolver, sf, dir)
+	}
+
+	fileCtx, cancel := context.WithTimeout(ctx, d.timeoutPerFile)
+	defer cancel()
+	path, err := d.writeSolutionFileWithin(fileCtx, resolver, sf, dir)
+	if err == nil || ctx.Err() != nil || fileCtx.Err() != context.DeadlineExceeded {
+		return path, err
+	}
+	if d.strict {
+		return "", fmt.Errorf("unable to download '%s': it took longer than the --timeout-per-file of %s", sf.path, d.timeoutPerFile)
+	}
+	warnf("Skipping '%s', it took longer than the --timeout-per-file of %s.", sf.path, d.timeoutPerFile)
+	return "", nil
+}
+
+// writeSolutionFileWithin downloads a single solution file into dir, for as long as the context allows.
+func (d *download) writeSolutionFileWithin(ctx context.Context, resolver *collisionResolver, sf solutionFile, dir string) (string, error) {
+	target := filepath.Join(dir, d.writePath(sf))
+	if !isWithinDir(dir, target) {
+		return "", fmt.Errorf("refusing to write '%s' outside of '%s'", sf.path, dir)
+	}
+
+	// The file is written next to the target, and moved into place once it's
+	// complete. A partial file left by an earlier attempt is continued,
+	// unless the complete contents are needed to compare with a local copy.
+	// What version of the file it holds is kept alongside, so that it's only
+	// continued with the rest of the same version.
+	part := target + ".part"
+	removePart := func() {
+		os.Remove(part)
+		os.Remove(part + partValidatorSuffix)
+	}
+	var offset int64
+	var validator string
+	if !d.interactive || d.forceoverwrite {
+		if info, err := os.Stat(part); err == nil {
+			if b, err := ioutil.ReadFile(part + partValidatorSuffix); err == nil && len(b) > 0 {
+				offset, validator = info.Size(), string(b)
+			}
+		}
+	}
+
+	res, err := d.requestFile(ctx, sf, offset, validator)
+	if err != nil || res == nil {
+		return "", err
+	}
+	defer res.body.Close()
+
+	if d.skipUnchanged && matchesETag(target, res.etag) {
+		removePart()
+		return target, nil
+	}
+
+	// The limit counts what's already in a partial file being continued.
+	var start int64
+	if res.partial {
+		start = offset
+	}
+	tooLarge := func(size int64) bool {
+		return d.maxFileSize > 0 && size > d.maxFileSize
+	}
+	errTooLarge := func() error {
+		removePart()
+		return fmt.Errorf("refusing to download '%s', it is larger than the --max-file-size of %d bytes", sf.path, d.maxFileSize)
+	}
+	if tooLarge(res.size) {
+		return "", errTooLarge()
+	}
+
+	var body io.Reader = res.body
+	if d.maxFileSize > 0 {
+		// Read a byte past the limit to tell when the server sends too much.
+		body = io.LimitReader(body, d.maxFileSize-start+1)
+	}
+	// An error or login page can be served in place of the file.
+	if d.rejectHTML && !res.partial && !isHTMLFile(target) {
+		sniffed := bufio.NewReader(body)
+		head, _ := sniffed.Peek(512)
+		if strings.HasPrefix(http.DetectContentType(head), "text/html") {
+			removePart()
+			return "", fmt.Errorf("refusing to write '%s', the server sent an HTML page instead of the file", sf.path)
+		}
+		body = sniffed
+	}
+	if d.interactive && !d.forceoverwrite {
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			return "", err
+		}
+		if tooLarge(start + int64(len(b))) {
+			return "", errTooLarge()
+		}
+		path, err := resolver.writePath(target, b)
+		if err != nil {
+			return "", err
+		}
+		if path == "" {
+			return "", nil
+		}
+		if path != target {
+			target, part = path, path+".part"
+		}
+		body = bytes.NewReader(b)
+	}
+
+	d.created.addIfMissing(outermostMissingPath(filepath.Dir(target)))
+	if err = mkdirWithin(dir, filepath.Dir(target)); err != nil {
+		return "", err
+	}
+
+	// Anything but the rest of the partial file starts it over.
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if res.partial {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	} else if err = d.writePartValidator(part, res.validator); err != nil {
+		return "", err
+	}
+	d.created.addIfMissing(part)
+	f, err := os.OpenFile(part, flag, os.FileMode(0644))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if d.isExecutable(target) {
+		if err := f.Chmod(os.FileMode(0755)); err != nil {
+			return "", err
+		}
+	}
+
+	// The system's error for a full disk doesn't say what was being written,
+	// and a partial file is no use to continue from once space is freed up.
+	diskFull := func(err error) error {
+		if !errors.Is(err, syscall.ENOSPC) {
+			return err
+		}
+		f.Close()
+		removePart()
+		return fmt.Errorf("ran out of disk space while writing '%s': %w", target, err)
+	}
+
+	n, err := io.Copy(f, body)
+	atomic.AddInt64(&d.bytesWritten, n)
+	if err != nil {
+		return "", diskFull(err)
+	}
+	if tooLarge(start + n) {
+		f.Close()
+		return "", errTooLarge()
+	}
+	if err = f.Close(); err != nil {
+		return "", diskFull(err)
+	}
+	d.created.addIfMissing(target)
+	if err = os.Rename(part, target); err != nil {
+		return "", err
+	}
+	os.Remove(part + partValidatorSuffix)
+	d.events.emit("file", map[string]interface{}{"name": sf.relativePath(), "path": target, "bytes": start + n})
+	return target, nil
+}
+
+// partValidatorSuffix names the file next to a partial file that says which
+// version of the file it's part of.
+const partValidatorSuffix = ".validator"
+
+// writePartValidator records which version of the file the partial file is
+// part of. Without a validator the partial file can't be continued, as there'd
+// be no telling whether the rest is of the same version.
+func (d *download) writePartValidator(part, validator string) error {
+	path := part + partValidatorSuffix
+	if validator == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	d.created.addIfMissing(path)
+	return ioutil.WriteFile(path, []byte(validator), os.FileMode(0644))
+}
+
+// createdFiles tracks the files and directories that a download creates,
+// so that an interrupted download doesn't leave any of them behind.
+type createdFiles struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+// addIfMissing tracks the path, unless something is already there.
+func (c *createdFiles) addIfMissing(path string) {
+	if c == nil {
+		return
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paths = append(c.paths, path)
+}
+
+// outermostMissingPath gives the outermost of path and its parents that
+// doesn't exist yet, which is what creating path creates.
+func outermostMissingPath(path string) string {
+	for {
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		if _, err := os.Lstat(parent); !os.IsNotExist(err) {
+			return path
+		}
+		path = parent
+	}
+}
+
+// remove deletes what was created, the most recent first.
+func (c *createdFiles) remove() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.paths) - 1; i >= 0; i-- {
+		os.RemoveAll(c.paths[i])
+	}
+	c.paths = nil
+}
+
+// do sends a request, logging it and its outcome when running verbosely.
+// A request that gets no response gives an ErrNetwork error,
+// unless it was canceled.
+func (d *download) do(req *http.Request) (*http.Response, error) {
+	res, err := d.client.Do(req)
+	if err != nil && req.Context().Err() == nil {
+		err = withKind(ErrNetwork, err)
+	}
+	if !debug.Verbose {
+		return res, err
+	}
+
+	outcome := ""
+	if err != nil {
+		outcome = err.Error()
+	} else {
+		outcome = res.Status
+	}
+	log := fmt.Sprintf("%s %s\n  Authorization: %s\n  %s\n", req.Method, req.URL, req.Header.Get("Authorization"), outcome)
+	if res != nil && res.Request != nil && res.Request.URL.String() != req.URL.String() {
+		log += fmt.Sprintf("  Redirected to %s\n", res.Request.URL)
+	}
+	if d.token != "" && !debug.UnmaskAPIKey {
+		log = strings.Replace(log, d.token, debug.Redact(d.token), -1)
+	}
+
+	warnMu.Lock()
+	defer warnMu.Unlock()
+	fmt.Fprint(Err, log)
+	return res, err
+}
+
+// fileSums gives the hex SHA-256 checksums of the files at paths, by their
+// slash-separated paths relative to dir.
+func fileSums(dir string, paths []string) (map[string]string, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	sums := make(map[string]string, len(paths))
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		sums[filepath.ToSlash(rel)] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums, nil
+}
+
+// matchesETag checks whether the file's MD5 or SHA-256 checksum is the ETag.
+func matchesETag(path, etag string) bool {
+	etag = strings.ToLower(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`))
+
+	var h hash.Hash
+	switch len(etag) {
+	case hex.EncodedLen(md5.Size):
+		h = md5.New()
+	case hex.EncodedLen(sha256.Size):
+		h = sha256.New()
+	default:
+		return false
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == etag
+}
+
+// mkdirWithin creates the directory path below dir, along with any missing parents,
+// like os.MkdirAll. Unlike os.MkdirAll, it refuses to follow a symlink out of dir,
+// so that an existing link can't send the files somewhere else.
+func mkdirWithin(dir, path string) error {
+	if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return err
+	}
+
+	current := dir
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		if name == "." {
+			continue
+		}
+		current = filepath.Join(current, name)
+		if _, err := os.Lstat(current); os.IsNotExist(err) {
+			// Another file being written may have created it in the meantime.
+			if err := os.Mkdir(current, os.FileMode(0755)); err != nil && !os.IsExist(err) {
+				return err
+			}
+		}
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			return err
+		}
+		if !isWithinDir(root, resolved) {
+			return fmt.Errorf("refusing to follow the link '%s' outside of '%s'", current, dir)
+		}
+	}
+	return nil
+}
+
+// isWithinDir checks that the path doesn't escape the directory.
+func isWithinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// warnMu serializes warnings and logs written by concurrent downloads.
+var warnMu sync.Mutex
+
+// warnf writes a warning to Err, in color on a terminal.
+func warnf(format string, args ...interface{}) {
+	warnMu.Lock()
+	defer warnMu.Unlock()
+
+	msg := fmt.Sprintf("WARNING: "+format, args...)
+	fmt.Fprintf(Err, "\n%s\n", colorize(Err, colorYellow, msg))
+}
+
+// isRedirect tells whether the response redirects elsewhere, rather than being the file.
+func isRedirect(res *http.Response) bool {
+	return res.StatusCode >= 300 && res.StatusCode < 400 && res.Header.Get("Location") != ""
+}
+
+// isHTMLFile checks whether the file is expected to be HTML.
+func isHTMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm", ".xhtml":
+		return true
+	}
+	return false
+}
+
+// isExecutable checks whether the file has one of the executable extensions.
+func (d *download) isExecutable(path string) bool {
+	ext := filepath.Ext(path)
+	if ext == "" {
+		return false
+	}
+	for _, e := range d.executableExts {
+		if strings.EqualFold(ext, "."+strings.TrimPrefix(e, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileResponse is the contents of a solution file as served.
+type fileResponse struct {
+	body io.ReadCloser
+	etag string
+	// validator identifies the version of the file, for If-Range.
+	validator string
+	// size is the size of the complete file, or -1 if it isn't known.
+	size int64
+	// partial is set when the body continues from the requested offset.
+	partial bool
+}
+
+// requestFile fetches the contents of a solution file, starting at the offset
+// if the server supports ranges and the file is still the version that the
+// validator names. It returns nil if there is nothing to write.
+func (d *download) requestFile(ctx context.Context, sf solutionFile, offset int64, validator string) (*fileResponse, error) {
+	if sf.inline {
+		if len(sf.contents) == 0 && !d.keepEmpty {
+			return nil, nil
+		}
+		return &fileResponse{body: ioutil.NopCloser(bytes.NewReader(sf.contents)), size: int64(len(sf.contents))}, nil
+	}
+
+	url, err := sf.url()
+	if err != nil {
+		return nil, fmt.Errorf("unable to download '%s': %w", sf.path, err)
+	}
+
+	req, err := d.client.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download '%s': %w", sf.path, err)
+	}
+	if offset > 0 {
+		// A file that changed since is sent whole, rather than its new tail.
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
+	} else {
+		// Asking for compression ourselves means it's always up to us to
+		// decompress, rather than only when the transport asked for it.
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+	}
+
+	res, err := d.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download '%s': %w", sf.path, err)
+	}
+	if isRedirect(res) {
+		res.Body.Close()
+		return nil, fmt.Errorf("unable to download '%s': it was redirected more than the --max-redirects of %d times", sf.path, d.maxRedirects)
+	}
+
+	// The partial file doesn't match what the server has, so start over.
+	if offset > 0 && res.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		res.Body.Close()
+		return d.requestFile(ctx, sf, 0, "")
+	}
+
+	partial := offset > 0 && res.StatusCode == http.StatusPartialContent
+	if res.StatusCode != http.StatusOK && !partial {
+		res.Body.Close()
+		if d.strict {
+			return nil, fmt.Errorf("unable to download '%s': %s", sf.path, res.Status)
+		}
+		warnf("Skipping '%s', the server responded with %s.", sf.path, res.Status)
+		return nil, nil
+	}
+	// Don't bother with empty files, unless they're wanted as placeholders.
+	if !partial && !d.keepEmpty && res.Header.Get("Content-Length") == "0" {
+		res.Body.Close()
+		return nil, nil
+	}
+	// A compressed part of a file can't be decompressed on its own.
+	encoded := contentEncoding(res) != ""
+	if partial && encoded {
+		res.Body.Close()
+		return d.requestFile(ctx, sf, 0, "")
+	}
+	body, err := decodedBody(res)
+	if err != nil {
+		res.Body.Close()
+		return nil, fmt.Errorf("unable to download '%s': %w", sf.path, err)
+	}
+	size := res.ContentLength
+	if encoded {
+		// The length is that of the compressed file.
+		size = -1
+	}
+	if partial && size >= 0 {
+		size += offset
+	}
+	return &fileResponse{body: body, etag: res.Header.Get("ETag"), validator: rangeValidator(res), size: size, partial: partial}, nil
+}
+
+// rangeValidator gives what identifies the version of the file in the
+// response, as If-Range takes it: a strong ETag, or else when it was last
+// modified. It's empty if the response has neither.
+func rangeValidator(res *http.Response) string {
+	if etag := res.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// contentEncoding gives the compression of the response body, if any.
+func contentEncoding(res *http.Response) string {
+	encoding := strings.ToLower(strings.TrimSpace(res.Header.Get("Content-Encoding")))
+	if encoding == "identity" {
+		return ""
+	}
+	return encoding
+}
+
+// decodedBody gives the response body, decompressed according to its Content-Encoding.
+func decodedBody(res *http.Response) (io.ReadCloser, error) {
+	var decoder io.ReadCloser
+	var err error
+	switch encoding := contentEncoding(res); encoding {
+	case "":
+		return res.Body, nil
+	case "gzip", "x-gzip":
+		decoder, err = gzip.NewReader(res.Body)
+	case "deflate":
+		decoder, err = zlib.NewReader(res.Body)
+	default:
+		return nil, fmt.Errorf("the server sent it with an unsupported encoding '%s'", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress it: %w", err)
+	}
+	return decompressingBody{ReadCloser: decoder, body: res.Body}, nil
+}
+
+// decompressingBody reads from a decompressor, and closes the response body with it.
+type decompressingBody struct {
+	io.ReadCloser
+	body io.Closer
+}
+
+func (b decompressingBody) Close() error {
+	b.ReadCloser.Close()
+	return b.body.Close()
+}
+
+// downloadProgress reports how many of a solution's files have been downloaded.
+type downloadProgress struct {
+	mu          sync.Mutex
+	done, total int
+	quiet       bool
+}
+
+func (p *downloadProgress) increment() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.done++
+	if !p.quiet {
+		fmt.Fprintf(Out, "Downloaded %d/%d files\n", p.done, p.total)
+	}
+}
+
+// downloadEvents streams the progress of a download as JSON lines, for --events.
+// A nil *downloadEvents emits nothing.
+type downloadEvents struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// emit writes an event of the given type, with its fields.
+func (e *downloadEvents) emit(event string, fields map[string]interface{}) {
+	if e == nil {
+		return
+	}
+	line := map[string]interface{}{"event": event}
+	for k, v := range fields {
+		line[k] = v
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	json.NewEncoder(e.w).Encode(line)
+}
+
+// defaultDownloadTimeout is how long to wait for a connection, and then for
+// the response headers, unless a timeout is configured.
+const defaultDownloadTimeout = 30 * time.Second
+
+// defaultMaxRedirects is as many redirects as Go's HTTP client follows by default.
+const defaultMaxRedirects = 10
+
+// defaultMaxRetries is how often a request that fails transiently is retried.
+const defaultMaxRetries = 2
+
+// defaultConcurrency is how many files are downloaded at a time.
+const defaultConcurrency = 4
+
+// defaultExecutableExts are the extensions of the files made executable.
+var defaultExecutableExts = []string{".sh"}
+
+// instructionsFilename is where --with-instructions writes the instructions.
+const instructionsFilename = "README.md"
+
+// solutionURLFilename is where --write-url writes the solution's URL.
+const solutionURLFilename = "SOLUTION_URL.txt"
+
+// The layouts of the workspace that --output-format chooses between.
+const (
+	// outputFormatNested puts team solutions under teams/<slug>,
+	// and other users' solutions under users/<handle>.
+	outputFormatNested = "nested"
+	// outputFormatFlat puts every solution directly under the workspace.
+	outputFormatFlat = "flat"
+)
+
+// These are the ways that --rename-collision handles a downloaded file
+// that differs from the local copy.
+const (
+	// collisionsSkip keeps the local copy, with a warning.
+	collisionsSkip = "skip"
+	// collisionsOverwrite replaces the local copy.
+	collisionsOverwrite = "overwrite"
+	// collisionsSuffix keeps the local copy, and writes the downloaded file
+	// next to it with a numeric suffix, as file (1).go.
+	collisionsSuffix = "suffix"
+	// collisionsPrompt asks what to do, as --interactive does.
+	collisionsPrompt = "prompt"
+)
+
+// defaultExercisePathTemplate lays out exercises as <track>/<slug>.
+const defaultExercisePathTemplate = "{track}/{slug}"
+
+// rgxPathPlaceholder matches the placeholders of an exercise path template.
+var rgxPathPlaceholder = regexp.MustCompile(`{([^{}]*)}`)
+
+// pathPlaceholders are the names that an exercise path template can refer to.
+var pathPlaceholders = []string{"track", "language", "slug", "handle", "team"}
+
+// The sources of a download, reported to the API so that the admins of
+// a site can tell fresh downloads from downloads of an exercise again.
+const (
+	sourceFlags    = "flags"
+	sourceExercise = "exercise"
+)
+
+// defaultPayloadCacheTTL is how long a solution payload is reused for by default,
+// which is not at all. The cache has to be asked for with --cache-ttl.
+const defaultPayloadCacheTTL = 0
+
+// hookDirEnv names the environment variable that tells the post-download hook
+// where the exercise was downloaded to.
+const hookDirEnv = "EXERCISM_DOWNLOAD_DIR"
+
+// defaultSolutionsPath is where the API serves solutions, below the API base URL.
+const defaultSolutionsPath = "/solutions"
+
+type download struct {
+	// bytes written by the solution files, updated atomically,
+	// so it comes first to be aligned on 32-bit platforms
+	bytesWritten int64
+
+	// either/or
+	slug, uuid string
+
+	// user config
+	token, apibaseurl, workspace string
+	apisolutionspath             string
+
+	// overrides the workspace-derived destination
+	outputDir string
+
+	// nested or flat, whether team and other users' solutions get their own directories
+	outputFormat string
+	// which of team and other users' solutions get their own directories when nested
+	nestTeams, nestUsers bool
+
+	// where an exercise goes below the workspace, or below its team or user directory
+	pathTemplate string
+
+	// sourceFlags or sourceExercise
+	source string
+
+	// a captured payload to use instead of asking the API
+	fromFile string
+
+	// how long a solution payload is reused for, unless noCache is set
+	cacheTTL time.Duration
+	noCache  bool
+
+	// optional
+	track, team    string
+	latest         bool
+	personal       bool
+	forceoverwrite bool
+	interactive    bool
+	nonInteractive bool
+	collisions     string
+	resume         bool
+	skipUnchanged  bool
+	keepEmpty      bool
+	rejectHTML     bool
+	quiet          bool
+	strict         bool
+	dryRun         bool
+	listFilesOnly  bool
+	// verifyOnly compares the local files with the solution instead of downloading it
+	verifyOnly bool
+	// compareUUID names a solution to diff with, instead of downloading either of them
+	compareUUID string
+	// printDestination limits the output to the exercise directory
+	printDestination bool
+	// stdout names the one solution file to write to Out, instead of downloading the exercise.
+	stdout           string
+	withInstructions bool
+	writeURL         bool
+	asJSON           bool
+	events           *downloadEvents
+	concurrency      int
+	maxFileSize      int64
+	maxRedirects     int
+	maxRetries       int
+	retryDelay       time.Duration
+	maxRetryWait     time.Duration
+	executableExts   []string
+	onlyFiles        []string
+	renames          map[string]string
+	created          *createdFiles
+	timeout          time.Duration
+	timeoutPerFile   time.Duration
+	proxy            string
+	userAgent        string
+	hook             string
+	gitCommit        bool
+	cacert           string
+	clientcert       string
+	clientkey        string
+	insecure         bool
+	tlsConfig        *tls.Config
+
+	// shared by every request made during the download
+	client *api.Client
+
+	payload *downloadPayload
+}
+
+// newDownloadFromExercise prepares to download the solution of the exercise
+// in dir again, into the same directory. Files with local changes are only
+// replaced when the flags include --force.
+func newDownloadFromExercise(ctx context.Context, dir string, flags *pflag.FlagSet, usrCfg *viper.Viper) (*download, error) {
+	metadata, err := workspace.NewExerciseMetadata(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, withKind(ErrMissingMetadata, fmt.Errorf("'%s' is not a downloaded exercise, it has no exercise metadata", dir))
+		}
+		return nil, err
+	}
+	if metadata.ID == "" {
+		return nil, withKind(ErrMissingMetadata, fmt.Errorf("the exercise metadata in '%s' doesn't identify the solution", dir))
+	}
+
+	downloadFlags := downloadFlagSet(flags)
+	force, _ := downloadFlags.GetBool("force")
+	downloadFlags.Set("uuid", metadata.ID)
+	downloadFlags.Set("output-dir", dir)
+	if collisions, _ := downloadFlags.GetString("rename-collision"); collisions == "" {
+		downloadFlags.Set("interactive", strconv.FormatBool(!force))
+	}
+
+	return newDownloadFrom(ctx, sourceExercise, downloadFlags, usrCfg)
+}
+
+// downloadFlagSet returns the download flags, taking the value of any that
+// the given flags share, so that other commands can run downloads.
+func downloadFlagSet(flags *pflag.FlagSet) *pflag.FlagSet {
+	downloadFlags := pflag.NewFlagSet("download", pflag.ContinueOnError)
+	setupDownloadFlags(downloadFlags)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if downloadFlags.Lookup(f.Name) == nil {
+			downloadFlags.AddFlag(f)
+		} else if f.Changed {
+			downloadFlags.Set(f.Name, f.Value.String())
+		}
+	})
+	return downloadFlags
+}
+
+func newDownload(ctx context.Context, flags *pflag.FlagSet, usrCfg *viper.Viper) (*download, error) {
+	return newDownloadFrom(ctx, sourceFlags, flags, usrCfg)
+}
+
+// newDownloadFrom sets up a download, noting whether the solution was picked
+// by flags or by the metadata of a downloaded exercise.
+func newDownloadFrom(ctx context.Context, source string, flags *pflag.FlagSet, usrCfg *viper.Viper) (*download, error) {
+	var err error
+	d := &download{source: source}
+	d.uuid, err = flags.GetString("uuid")
+	if err != nil {
+		return nil, err
+	}
+	d.slug, err = flags.GetString("exercise")
+	if err != nil {
+		return nil, err
+	}
+	d.track, err = flags.GetString("track")
+	if err != nil {
+		return nil, err
+	}
+	d.team, err = flags.GetString("team")
+	if err != nil {
+		return nil, err
+	}
+	if solutionURL, _ := flags.GetString("url"); solutionURL != "" {
+		if err = d.pickBySolutionURL(solutionURL); err != nil {
+			return nil, err
+		}
+	}
+
+	d.fromFile, err = flags.GetString("from-file")
+	if err != nil {
+		return nil, err
+	}
+
+	d.latest, err = flags.GetBool("latest")
+	if err != nil {
+		return nil, err
+	}
+	d.personal, err = flags.GetBool("personal")
+	if err != nil {
+		return nil, err
+	}
+
+	d.outputDir, err = flags.GetString("output-dir")
+	if err != nil {
+		return nil, err
+	}
+	if d.outputDir, err = absOutputDir(d.outputDir); err != nil {
+		return nil, err
+	}
+
+	d.outputFormat, err = flags.GetString("output-format")
+	if err != nil {
+		return nil, err
+	}
+	// --no-nested is short for --output-format=flat.
+	if noNested, _ := flags.GetBool("no-nested"); noNested {
+		if flags.Changed("output-format") && d.outputFormat != outputFormatFlat {
+			return nil, fmt.Errorf("--no-nested can't be used with --output-format=%s", d.outputFormat)
+		}
+		d.outputFormat = outputFormatFlat
+	}
+	d.nestTeams, err = flags.GetBool("nest-teams")
+	if err != nil {
+		return nil, err
+	}
+	d.nestUsers, err = flags.GetBool("nest-users")
+	if err != nil {
+		return nil, err
+	}
+
+	d.forceoverwrite, err = flags.GetBool("force")
+	if err != nil {
+		return nil, err
+	}
+	d.interactive, err = flags.GetBool("interactive")
+	if err != nil {
+		return nil, err
+	}
+	d.collisions, err = flags.GetString("rename-collision")
+	if err != nil {
+		return nil, err
+	}
+	if d.collisions != "" {
+		if d.forceoverwrite || d.interactive {
+			return nil, errors.New("--rename-collision can't be used with --force or --interactive")
+		}
+		switch d.collisions {
+		case collisionsOverwrite:
+			d.forceoverwrite = true
+		case collisionsSkip, collisionsSuffix, collisionsPrompt:
+			// Each file is compared with its local copy before it's written.
+			d.interactive = true
+		default:
+			return nil, fmt.Errorf("--rename-collision must be one of '%s', '%s', '%s', or '%s', not '%s'",
+				collisionsSkip, collisionsOverwrite, collisionsSuffix, collisionsPrompt, d.collisions)
+		}
+	}
+	d.nonInteractive, err = flags.GetBool("non-interactive")
+	if err != nil {
+		return nil, err
+	}
+	d.resume, err = flags.GetBool("resume")
+	if err != nil {
+		return nil, err
+	}
+	d.skipUnchanged, err = flags.GetBool("skip-unchanged")
+	if err != nil {
+		return nil, err
+	}
+	d.keepEmpty, err = flags.GetBool("keep-empty")
+	if err != nil {
+		return nil, err
+	}
+	d.rejectHTML, err = flags.GetBool("reject-html")
+	if err != nil {
+		return nil, err
+	}
+	d.onlyFiles, err = flags.GetStringSlice("file")
+	if err != nil {
+		return nil, err
+	}
+	d.quiet = quiet
+	d.strict, err = flags.GetBool("strict")
+	if err != nil {
+		return nil, err
+	}
+	d.dryRun, err = flags.GetBool("dry-run")
+	if err != nil {
+		return nil, err
+	}
+	d.listFilesOnly, err = flags.GetBool("list-files-only")
+	if err != nil {
+		return nil, err
+	}
+	d.stdout, err = flags.GetString("stdout")
+	if err != nil {
+		return nil, err
+	}
+	d.verifyOnly, err = flags.GetBool("verify-only")
+	if err != nil {
+		return nil, err
+	}
+	d.compareUUID, err = flags.GetString("compare-uuid")
+	if err != nil {
+		return nil, err
+	}
+	d.printDestination, err = flags.GetBool("print-destination")
+	if err != nil {
+		return nil, err
+	}
+	// Nothing but the destination may be written to Out.
+	d.quiet = d.quiet || d.printDestination
+	d.withInstructions, err = flags.GetBool("with-instructions")
+	if err != nil {
+		return nil, err
+	}
+	d.writeURL, err = flags.GetBool("write-url")
+	if err != nil {
+		return nil, err
+	}
+	d.asJSON, err = flags.GetBool("json")
+	if err != nil {
+		return nil, err
+	}
+	withEvents, err := flags.GetBool("events")
+	if err != nil {
+		return nil, err
+	}
+	if withEvents {
+		d.events = &downloadEvents{w: Out}
+	}
+	d.executableExts, err = flags.GetStringSlice("executable-ext")
+	if err != nil {
+		return nil, err
+	}
+	renames, err := flags.GetStringArray("rename")
+	if err != nil {
+		return nil, err
+	}
+	if d.renames, err = parseRenames(renames); err != nil {
+		return nil, err
+	}
+	d.concurrency, err = flags.GetInt("concurrency")
+	if err != nil {
+		return nil, err
+	}
+	d.noCache, err = flags.GetBool("no-cache")
+	if err != nil {
+		return nil, err
+	}
+	d.cacheTTL, err = flags.GetDuration("cache-ttl")
+	if err != nil {
+		return nil, err
+	}
+	d.maxFileSize, err = flags.GetInt64("max-file-size")
+	if err != nil {
+		return nil, err
+	}
+	d.timeoutPerFile, err = flags.GetDuration("timeout-per-file")
+	if err != nil {
+		return nil, err
+	}
+
+	d.gitCommit, err = flags.GetBool("git-commit")
+	if err != nil {
+		return nil, err
+	}
+	d.hook, err = flags.GetString("hook")
+	if err != nil {
+		return nil, err
+	}
+
+	if err = d.readClientSettings(flags, usrCfg); err != nil {
+		return nil, err
+	}
+	if err = d.prepare(ctx, usrCfg); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// newDownloadFromOptions sets up a download for a program that embeds the CLI.
+// What the options don't cover is as the download command has it by default,
+// except that nothing is written to Out, and nothing is asked.
+func newDownloadFromOptions(ctx context.Context, opts DownloadOptions, usrCfg *viper.Viper) (*download, error) {
+	var err error
+	d := &download{
+		source:           sourceFlags,
+		uuid:             opts.UUID,
+		slug:             opts.Exercise,
+		track:            opts.Track,
+		team:             opts.Team,
+		personal:         opts.Personal,
+		forceoverwrite:   opts.Force,
+		withInstructions: opts.WithInstructions,
+		nonInteractive:   true,
+		quiet:            true,
+		outputFormat:     outputFormatNested,
+		nestTeams:        true,
+		nestUsers:        true,
+		concurrency:      defaultConcurrency,
+		cacheTTL:         defaultPayloadCacheTTL,
+		executableExts:   defaultExecutableExts,
+		renames:          map[string]string{},
+	}
+	if opts.URL != "" {
+		if err = d.pickBySolutionURL(opts.URL); err != nil {
+			return nil, err
+		}
+	}
+	if d.outputDir, err = absOutputDir(opts.OutputDir); err != nil {
+		return nil, err
+	}
+
+	if err = d.readClientConfig(usrCfg); err != nil {
+		return nil, err
+	}
+	if err = d.prepare(ctx, usrCfg); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// pickBySolutionURL picks the solution by its URL on the website,
+// in place of its UUID or exercise.
+func (d *download) pickBySolutionURL(solutionURL string) error {
+	if d.uuid != "" || d.slug != "" {
+		return errors.New("--url cannot be used with --uuid or --exercise")
+	}
+	return d.parseSolutionURL(solutionURL)
+}
+
+// absOutputDir makes the --output-dir absolute, if there is one.
+func absOutputDir(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	return filepath.Abs(config.Expand(dir))
+}
+
+// prepare reads the rest of the user config, checks that the settings make
+// sense together, and gets the solution's payload, once the settings that
+// the flags or options give are in place.
+func (d *download) prepare(ctx context.Context, usrCfg *viper.Viper) error {
+	var err error
+	if d.hook == "" {
+		d.hook = usrCfg.GetString("postdownloadhook")
+	}
+	d.apisolutionspath = strings.TrimSpace(usrCfg.GetString("apisolutionspath"))
+	d.pathTemplate = strings.TrimSpace(usrCfg.GetString("exercisepathtemplate"))
+	if d.pathTemplate == "" {
+		d.pathTemplate = defaultExercisePathTemplate
+	}
+	if d.apisolutionspath == "" {
+		d.apisolutionspath = defaultSolutionsPath
+	}
+	d.workspace = userWorkspace(usrCfg)
+	// Those who mostly work in one track can leave out --track.
+	if d.track == "" && d.slug != "" {
+		d.track = strings.TrimSpace(usrCfg.GetString("defaulttrack"))
+	}
+
+	if err = d.needsLatestXorUUID(); err != nil {
+		return err
+	}
+	if err = d.needsSlugXorUUID(); err != nil {
+		return err
+	}
+	if err = d.needsUserConfigValues(); err != nil {
+		return err
+	}
+	if err = d.needsSlugWhenGivenTrackOrTeam(); err != nil {
+		return err
+	}
+	if err = d.needsPersonalXorTeam(); err != nil {
+		return err
+	}
+	if err = d.needsPositiveConcurrency(); err != nil {
+		return err
+	}
+	if err = d.needsNonNegativeMaxFileSize(); err != nil {
+		return err
+	}
+	if err = d.needsNonNegativeMaxRedirects(); err != nil {
+		return err
+	}
+	if err = d.needsNonNegativeMaxRetries(); err != nil {
+		return err
+	}
+	if err = d.needsNonNegativeTimeoutPerFile(); err != nil {
+		return err
+	}
+	if err = d.needsWritableOutputDir(); err != nil {
+		return err
+	}
+	if err = d.needsValidOutputFormat(); err != nil {
+		return err
+	}
+	if err = d.needsValidPathTemplate(); err != nil {
+		return err
+	}
+	if err = d.needsJSONXorEvents(); err != nil {
+		return err
+	}
+	if err = d.needsStdoutAlone(); err != nil {
+		return err
+	}
+	if err = d.needsPrintDestinationAlone(); err != nil {
+		return err
+	}
+	if err = d.needsVerifyOnlyAlone(); err != nil {
+		return err
+	}
+	if err = d.needsUUIDToCompare(); err != nil {
+		return err
+	}
+	if err = d.needsValidProxy(); err != nil {
+		return err
+	}
+	if err = d.needsValidSolutionsPath(); err != nil {
+		return err
+	}
+	if err = d.needsPromptsAllowed(); err != nil {
+		return err
+	}
+	if err = d.needsWorkspace(); err != nil {
+		return err
+	}
+	if err = d.setupClient(); err != nil {
+		return err
+	}
+
+	if d.fromFile != "" {
+		err = d.loadPayload()
+	} else if cache := d.payloadCachePath(); !d.loadCachedPayload(cache) {
+		if err = d.requestPayload(ctx); err == nil {
+			d.cachePayload(cache)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.payload.validate(); err != nil {
+		return err
+	}
+	if err := d.needsKnownFiles(); err != nil {
+		return err
+	}
+	if d.fromFile == "" {
+		d.checkSolutionHost()
+	}
+	return nil
+}
+
+// readClientSettings reads the settings of the connection to the API: the
+// token and base URL, and the timeout, retry, redirect, proxy, TLS, and
+// User-Agent settings. The flags win over the user config.
+func (d *download) readClientSettings(flags *pflag.FlagSet, usrCfg *viper.Viper) error {
+	err := d.readClientConfig(usrCfg)
+	if err != nil {
+		return err
+	}
+	if flags.Changed("max-redirects") {
+		if d.maxRedirects, err = flags.GetInt("max-redirects"); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("max-retries") {
+		if d.maxRetries, err = flags.GetInt("max-retries"); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("retry-delay") {
+		if d.retryDelay, err = flags.GetDuration("retry-delay"); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("max-retry-wait") {
+		if d.maxRetryWait, err = flags.GetDuration("max-retry-wait"); err != nil {
+			return err
+		}
+	}
+	// The timeout flag is inherited from the root command.
+	if seconds, _ := flags.GetInt("timeout"); seconds > 0 {
+		d.timeout = time.Duration(seconds) * time.Second
+	}
+
+	for name, setting := range map[string]*string{
+		"proxy":      &d.proxy,
+		"cacert":     &d.cacert,
+		"clientcert": &d.clientcert,
+		"clientkey":  &d.clientkey,
+		"user-agent": &d.userAgent,
+	} {
+		value, err := flags.GetString(name)
+		if err != nil {
+			return err
+		}
+		if value != "" {
+			*setting = value
+		}
+	}
+
+	token, err := flags.GetString("token")
+	if err != nil {
+		return err
+	}
+	if token = strings.TrimSpace(token); token != "" {
+		d.token = token
+	}
+	return nil
+}
+
+// readClientConfig reads the settings of the connection to the API from the
+// user config, where they're set, or else takes their defaults.
+func (d *download) readClientConfig(usrCfg *viper.Viper) error {
+	var err error
+	d.maxRedirects = defaultMaxRedirects
+	d.maxRetries = defaultMaxRetries
+	if usrCfg.IsSet("maxretries") {
+		d.maxRetries = usrCfg.GetInt("maxretries")
+	}
+	d.retryDelay = api.DefaultRetryBaseDelay
+	if usrCfg.IsSet("retrydelay") {
+		if d.retryDelay, err = configDuration(usrCfg, "retrydelay"); err != nil {
+			return err
+		}
+	}
+	d.maxRetryWait = api.DefaultRetryMaxDelay
+	if usrCfg.IsSet("maxretrywait") {
+		if d.maxRetryWait, err = configDuration(usrCfg, "maxretrywait"); err != nil {
+			return err
+		}
+	}
+
+	d.timeout = defaultDownloadTimeout
+	if seconds := usrCfg.GetInt("httptimeout"); seconds > 0 {
+		d.timeout = time.Duration(seconds) * time.Second
+	}
+
+	d.proxy = usrCfg.GetString("proxyurl")
+	d.cacert = usrCfg.GetString("cacert")
+	// The insecure flag is inherited from the root command.
+	d.insecure = insecure || usrCfg.GetBool("insecure")
+	d.clientcert = usrCfg.GetString("clientcert")
+	d.clientkey = usrCfg.GetString("clientkey")
+	d.userAgent = usrCfg.GetString("useragent")
+
+	d.token = strings.TrimSpace(usrCfg.GetString("token"))
+	// Pasted config values often come with stray whitespace,
+	// and a trailing slash on the base URL would double up in the request URL.
+	d.apibaseurl = strings.TrimSuffix(strings.TrimSpace(usrCfg.GetString("apibaseurl")), "/")
+	return nil
+}
+
+// configDuration reads a duration from the user config. A bare number is a
+// number of seconds, as with httptimeout, and anything else must be a
+// duration with its unit, such as 500ms or 2s.
+func configDuration(usrCfg *viper.Viper, key string) (time.Duration, error) {
+	value := strings.TrimSpace(usrCfg.GetString(key))
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, withKind(ErrMissingConfig, fmt.Errorf("%s in the user config must be a number of seconds or a duration such as 500ms, not '%s'", key, value))
+	}
+	return duration, nil
+}
+
+// setupClient builds the API client from the connection settings.
+func (d *download) setupClient() error {
+	var err error
+	if d.tlsConfig, err = d.loadTLSConfig(); err != nil {
+		return err
+	}
+	if d.insecure {
+		warnf("TLS certificates are not being verified. Anyone on the network can read and change the download, and see your token. Only use --insecure for testing.")
+	}
+
+	d.client, err = api.NewClient(d.token, d.apibaseurl)
+	if err != nil {
+		return err
+	}
+	d.client.Client = d.httpClient()
+	d.client.Retry = d.retryPolicy()
+	d.client.UserAgent = d.userAgent
+	return nil
+}
+
+// newAPIClient builds an API client that connects as a download would,
+// for commands that talk to the API without downloading a solution.
+func newAPIClient(flags *pflag.FlagSet, usrCfg *viper.Viper) (*api.Client, error) {
+	d := &download{}
+	if err := d.readClientSettings(downloadFlagSet(flags), usrCfg); err != nil {
+		return nil, err
+	}
+	if err := d.needsNonNegativeMaxRedirects(); err != nil {
+		return nil, err
+	}
+	if err := d.needsNonNegativeMaxRetries(); err != nil {
+		return nil, err
+	}
+	if err := d.needsValidProxy(); err != nil {
+		return nil, err
+	}
+	if err := d.setupClient(); err != nil {
+		return nil, err
+	}
+	return d.client, nil
+}
+
+// checkSolutionHost warns when the solution is on a different site than the one
+// the API belongs to, such as when the URL of a solution on another instance of
+// the website was pasted while the user config points at this one.
+// An API on an api. subdomain belongs to the site of the parent domain.
+func (d download) checkSolutionHost() {
+	solutionHost := siteHost(d.payload.Solution.URL)
+	apiHost := strings.TrimPrefix(siteHost(config.InferSiteURL(d.apibaseurl)), "api.")
+	if solutionHost == "" || apiHost == "" || solutionHost == apiHost {
+		return
+	}
+	warnf("The solution is on %s, but the configured API is that of %s. Check the apibaseurl in the user config.", solutionHost, apiHost)
+}
+
+// siteHost gives the host name of the URL, without a leading www.
+func siteHost(url string) string {
+	u, err := netURL.Parse(url)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// requestPayload asks the API for the solution.
+func (d *download) requestPayload(ctx context.Context) error {
+	req, err := d.client.NewRequestWithContext(ctx, "GET", d.url(), nil)
+	if err != nil {
+		return err
+	}
+	d.buildQueryParams(req.URL)
+
+	res, err := d.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		return withKind(ErrRateLimited, errors.New("the API is rate limiting requests, please try again later"))
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		err := decodedAPIError(res)
+		var ambiguous *ambiguousError
+		if errors.As(err, &ambiguous) {
+			ambiguous.slug, ambiguous.track, ambiguous.team = d.slug, d.track, d.team
+		}
+		return err
+	}
+
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if err := json.Unmarshal(body, &d.payload); err != nil {
+		return decodedAPIError(res)
+	}
+	return nil
+}
+
+// loadPayload reads a previously captured solution payload from disk.
+func (d *download) loadPayload() error {
+	b, err := ioutil.ReadFile(d.fromFile)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, &d.payload); err != nil {
+		return fmt.Errorf("unable to parse '%s': %s", d.fromFile, err)
+	}
+	return nil
+}
+
+// payloadCacheDir is where solution payloads are cached between runs.
+// It returns an empty path when there's nowhere to cache them.
+var payloadCacheDir = func() string {
+	dir, err := os.UserCacheDir()
 	if err != nil {
-		return nil, err
+		return ""
 	}
-	d.slug, err = flags.GetString("exercise")
-	if err != nil {
-		return nil, err
+	return filepath.Join(dir, "exercism", "payloads")
+}
+
+// payloadCachePath is where the payload of this download is cached,
+// or an empty path if it isn't to be cached.
+// The path depends on the token, so that users don't share payloads.
+// Asking for the latest solution, overwriting, or refreshing an exercise
+// wants the solution as it is now, so those never use the cache.
+func (d download) payloadCachePath() string {
+	dir := payloadCacheDir()
+	if d.noCache || d.cacheTTL <= 0 || dir == "" {
+		return ""
 	}
-	d.track, err = flags.GetString("track")
+	if d.latest || d.forceoverwrite || d.source == sourceExercise {
+		return ""
+	}
+	url, err := netURL.Parse(d.url())
 	if err != nil {
-		return nil, err
+		return ""
 	}
-	d.team, err = flags.GetString("team")
+	d.buildQueryParams(url)
+	sum := sha256.Sum256([]byte(d.token + "\n" + url.String()))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// loadCachedPayload uses the cached payload at path, if it's there and
+// younger than the cache TTL. It reports whether it did.
+func (d *download) loadCachedPayload(path string) bool {
+	if path == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > d.cacheTTL {
+		return false
+	}
+	b, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return false
+	}
+	var payload downloadPayload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return false
 	}
+	debug.Printf("using the cached solution payload in %s\n", path)
+	d.payload = &payload
+	return true
+}
 
-	d.forceoverwrite, err = flags.GetBool("force")
+// cachePayload saves the payload at path for later runs.
+// The cache is only an optimization, so failing to write it isn't an error.
+func (d *download) cachePayload(path string) {
+	if path == "" {
+		return
+	}
+	b, err := json.Marshal(d.payload)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), os.FileMode(0700))
+	}
+	if err == nil {
+		err = ioutil.WriteFile(path, b, os.FileMode(0600))
+	}
 	if err != nil {
-		return nil, err
+		debug.Printf("unable to cache the solution payload: %s\n", err)
 	}
+}
 
-	d.token = usrCfg.GetString("token")
-	d.apibaseurl = usrCfg.GetString("apibaseurl")
-	d.workspace = usrCfg.GetString("workspace")
+// allowsExistingDestination checks whether files may be written into an
+// exercise directory that already exists.
+func (d download) allowsExistingDestination() bool {
+	return d.forceoverwrite || d.interactive || d.resume
+}
 
-	if err = d.needsSlugXorUUID(); err != nil {
-		return nil, err
+// destination is the directory the solution is written to.
+// It is derived from the workspace unless an output directory was given.
+func (d download) destination() string {
+	if d.outputDir != "" {
+		return d.outputDir
 	}
-	if err = d.needsUserConfigValues(); err != nil {
-		return nil, err
+	// The template takes the place of <track>/<slug> under the root.
+	return filepath.Join(d.solutionRoot(), filepath.FromSlash(d.exercisePath()))
+}
+
+// solutionRoot is the directory below which the exercise goes. Unless the layout
+// is flat, team solutions go under teams/<slug>, and other users' solutions
+// under users/<handle>, as far as --nest-teams and --nest-users allow.
+func (d download) solutionRoot() string {
+	if d.outputFormat == outputFormatFlat {
+		return d.workspace
 	}
-	if err = d.needsSlugWhenGivenTrackOrTeam(); err != nil {
-		return nil, err
+	metadata := d.metadata()
+	switch {
+	case metadata.Team != "" && d.nestTeams:
+		return filepath.Join(d.workspace, "teams", metadata.Team)
+	case !metadata.IsRequester && d.nestUsers:
+		return filepath.Join(d.workspace, "users", metadata.Handle)
 	}
+	return d.workspace
+}
 
-	client, err := api.NewClient(d.token, d.apibaseurl)
-	if err != nil {
-		return nil, err
+// pathFields are the values that the exercise path template can refer to.
+func (d download) pathFields() map[string]string {
+	solution := d.payload.Solution
+	team := solution.Team.Slug
+	if d.personal {
+		team = ""
+	}
+	return map[string]string{
+		"track":    solution.Exercise.Track.ID,
+		"language": solution.Exercise.Track.Language,
+		"slug":     solution.Exercise.ID,
+		"handle":   solution.User.Handle,
+		"team":     team,
 	}
+}
 
-	req, err := client.NewRequest("GET", d.url(), nil)
-	if err != nil {
-		return nil, err
+// exercisePath expands the exercise path template.
+// A placeholder without a value leaves out its part of the path.
+func (d download) exercisePath() string {
+	fields := d.pathFields()
+	return rgxPathPlaceholder.ReplaceAllStringFunc(d.pathTemplate, func(placeholder string) string {
+		return fields[strings.Trim(placeholder, "{}")]
+	})
+}
+
+// metadata describes the downloaded solution, and when and where it was downloaded from.
+// A personal download is never nested under a team.
+func (d download) metadata() workspace.ExerciseMetadata {
+	metadata := d.payload.metadata()
+	if d.personal {
+		metadata.Team = ""
 	}
-	d.buildQueryParams(req.URL)
+	now := time.Now().UTC()
+	metadata.DownloadedAt = &now
+	metadata.APIBaseURL = d.apibaseurl
+	metadata.Renamed = d.renamed()
+	return metadata
+}
 
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// parseRenames reads the --rename mappings of one file extension to another.
+// The leading dots are optional.
+func parseRenames(mappings []string) (map[string]string, error) {
+	renames := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		parts := strings.SplitN(mapping, "=", 2)
+		if len(parts) != 2 || strings.Trim(parts[0], ".") == "" || strings.Trim(parts[1], ".") == "" {
+			return nil, fmt.Errorf("--rename '%s' must map one extension to another, as in .example=.go", mapping)
+		}
+		from := "." + strings.TrimPrefix(strings.TrimSpace(parts[0]), ".")
+		to := "." + strings.TrimPrefix(strings.TrimSpace(parts[1]), ".")
+		renames[from] = to
 	}
-	defer res.Body.Close()
+	return renames, nil
+}
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, decodedAPIError(res)
+// httpClient is used for every request made during the download.
+// Without an explicit proxy the proxy environment variables are honored.
+func (d download) httpClient() *http.Client {
+	client := &http.Client{}
+	// Past the limit, the redirect itself is the response, which tells
+	// what went wrong better than an error that would be retried.
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > d.maxRedirects {
+			return http.ErrUseLastResponse
+		}
+		return nil
 	}
 
-	body, _ := ioutil.ReadAll(res.Body)
-	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	// The timeout bounds connecting and waiting for the response, not reading
+	// it, so that a large file arriving slowly but steadily isn't cut off.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   d.timeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.ResponseHeaderTimeout = d.timeout
+	if proxy, _ := d.proxyURL(); proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if d.tlsConfig != nil {
+		transport.TLSClientConfig = d.tlsConfig
+	}
+	client.Transport = transport
+	return client
+}
 
-	if err := json.Unmarshal(body, &d.payload); err != nil {
-		return nil, decodedAPIError(res)
+// loadTLSConfig trusts the extra certificate authority and presents the
+// client certificate, if any are configured, for self-hosted instances.
+// When insecure, certificates aren't verified at all.
+func (d download) loadTLSConfig() (*tls.Config, error) {
+	if d.cacert == "" && d.clientcert == "" && d.clientkey == "" && !d.insecure {
+		return nil, nil
 	}
+	cfg := &tls.Config{InsecureSkipVerify: d.insecure}
 
-	return d, nil
+	if d.cacert != "" {
+		pem, err := ioutil.ReadFile(d.cacert)
+		if err != nil {
+			return nil, fmt.Errorf("--cacert: unable to read '%s': %s", d.cacert, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("--cacert: '%s' has no PEM encoded certificates", d.cacert)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if d.clientcert != "" || d.clientkey != "" {
+		if d.clientcert == "" || d.clientkey == "" {
+			return nil, errors.New("--clientcert and --clientkey must be used together")
+		}
+		cert, err := tls.LoadX509KeyPair(d.clientcert, d.clientkey)
+		if err != nil {
+			return nil, fmt.Errorf("--clientcert: unable to load '%s' with key '%s': %s", d.clientcert, d.clientkey, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// proxyURL parses the configured proxy, if any.
+func (d download) proxyURL() (*netURL.URL, error) {
+	if d.proxy == "" {
+		return nil, nil
+	}
+	return netURL.Parse(d.proxy)
+}
+
+// retryPolicy retries transient failures with exponential backoff.
+// Rate limited requests wait as long as the server asks, up to maxRetryWait.
+func (d download) retryPolicy() api.RetryPolicy {
+	return api.RetryPolicy{MaxRetries: d.maxRetries, BaseDelay: d.retryDelay, MaxDelay: d.maxRetryWait}
 }
 
 func (d download) url() string {
-	id := "latest"
-	if d.uuid != "" {
-		id = d.uuid
+	id := d.uuid
+	if d.latest || id == "" {
+		id = "latest"
 	}
-	return fmt.Sprintf("%s/solutions/%s", d.apibaseurl, id)
+	return fmt.Sprintf("%s%s/%s", d.apibaseurl, strings.TrimSuffix(d.apisolutionspath, "/"), id)
 }
 
 func (d download) buildQueryParams(url *netURL.URL) {
@@ -227,32 +2634,92 @@ func (d download) buildQueryParams(url *netURL.URL) {
 		if d.track != "" {
 			query.Add("track_id", d.track)
 		}
-		if d.team != "" {
+		if d.team != "" && !d.personal {
 			query.Add("team_id", d.team)
 		}
 	}
+	if d.source != "" {
+		query.Add("source", d.source)
+	}
 	url.RawQuery = query.Encode()
 }
 
+// needsLatestXorUUID ensures that --latest isn't given along with a solution UUID.
+func (d download) needsLatestXorUUID() error {
+	if d.latest && d.uuid != "" {
+		return errors.New("--latest and --uuid cannot be used together, a solution UUID picks one solution")
+	}
+	return nil
+}
+
 // needsSlugXorUUID checks the presence of slug XOR uuid.
+// A captured payload already identifies the solution.
 func (d download) needsSlugXorUUID() error {
+	if d.fromFile != "" {
+		return nil
+	}
 	if d.slug != "" && d.uuid != "" || d.uuid == d.slug {
 		return errors.New("need an --exercise name or a solution --uuid")
 	}
 	return nil
 }
 
+// parseSolutionURL fills in the solution to download from a website URL.
+// Solution URLs like https://exercism.io/my/solutions/UUID give the uuid,
+// and exercise URLs like https://exercism.io/tracks/TRACK/exercises/SLUG
+// (optionally below /teams/TEAM) give the track and exercise.
+func (d *download) parseSolutionURL(solutionURL string) error {
+	u, err := netURL.Parse(solutionURL)
+	if err != nil {
+		return fmt.Errorf("--url: '%s' is not a valid URL", solutionURL)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	var uuid, team, track, slug string
+	for i := 0; i+1 < len(segments); i++ {
+		switch segments[i] {
+		case "solutions":
+			uuid = segments[i+1]
+		case "teams":
+			team = segments[i+1]
+		case "tracks":
+			track = segments[i+1]
+		case "exercises":
+			slug = segments[i+1]
+		}
+	}
+
+	switch {
+	case uuid != "":
+		d.uuid = uuid
+	case track != "" && slug != "":
+		d.track, d.slug = track, slug
+		if team != "" {
+			d.team = team
+		}
+	default:
+		return fmt.Errorf("--url: '%s' is not a solution or exercise URL", solutionURL)
+	}
+	return nil
+}
+
 // needsUserConfigValues checks the presence of required values from the user config.
 func (d download) needsUserConfigValues() error {
 	errMsg := "missing required user config: '%s'"
+	if d.fromFile != "" {
+		if d.workspace == "" {
+			return withKind(ErrMissingConfig, fmt.Errorf(errMsg, "workspace"))
+		}
+		return nil
+	}
 	if d.token == "" {
-		return fmt.Errorf(errMsg, "token")
+		return withKind(ErrMissingConfig, fmt.Errorf(errMsg, "token"))
 	}
 	if d.apibaseurl == "" {
-		return fmt.Errorf(errMsg, "apibaseurl")
+		return withKind(ErrMissingConfig, fmt.Errorf(errMsg, "apibaseurl"))
 	}
 	if d.workspace == "" {
-		return fmt.Errorf(errMsg, "workspace")
+		return withKind(ErrMissingConfig, fmt.Errorf(errMsg, "workspace"))
 	}
 	return nil
 }
@@ -266,6 +2733,259 @@ func (d download) needsSlugWhenGivenTrackOrTeam() error {
 	return nil
 }
 
+// needsPersonalXorTeam ensures that a personal download doesn't also name a team.
+func (d download) needsPersonalXorTeam() error {
+	if d.personal && d.team != "" {
+		return errors.New("--personal and --team cannot be used together")
+	}
+	return nil
+}
+
+// needsPositiveConcurrency ensures that at least one file is downloaded at a time.
+func (d download) needsPositiveConcurrency() error {
+	if d.concurrency < 1 {
+		return errors.New("--concurrency must be at least 1")
+	}
+	return nil
+}
+
+// needsJSONXorEvents ensures that only one kind of JSON output is asked for.
+func (d download) needsJSONXorEvents() error {
+	if d.asJSON && d.events != nil {
+		return errors.New("--json and --events can't be used together")
+	}
+	return nil
+}
+
+// needsStdoutAlone ensures that nothing else is written to Out along with the file.
+func (d download) needsStdoutAlone() error {
+	if d.stdout == "" {
+		return nil
+	}
+	if d.asJSON || d.events != nil || d.dryRun || d.listFilesOnly {
+		return errors.New("--stdout can't be used with --json, --events, --dry-run, or --list-files-only")
+	}
+	return nil
+}
+
+// needsPrintDestinationAlone ensures that the destination isn't mixed up with other output.
+func (d download) needsPrintDestinationAlone() error {
+	if d.printDestination && (d.asJSON || d.events != nil || d.listFilesOnly || d.stdout != "") {
+		return errors.New("--print-destination can't be used with --json, --events, --list-files-only, or --stdout")
+	}
+	return nil
+}
+
+// needsUUIDToCompare ensures that there are two solutions to compare, and
+// that comparing them isn't mistaken for downloading.
+func (d download) needsUUIDToCompare() error {
+	if d.compareUUID == "" {
+		return nil
+	}
+	if d.uuid == "" {
+		return errors.New("--compare-uuid needs a solution --uuid to compare with")
+	}
+	if d.asJSON || d.events != nil || d.dryRun || d.listFilesOnly || d.stdout != "" || d.verifyOnly || d.printDestination {
+		return errors.New("--compare-uuid can't be used with --json, --events, --dry-run, --list-files-only, --stdout, --verify-only, or --print-destination")
+	}
+	return nil
+}
+
+// needsVerifyOnlyAlone ensures that verifying isn't mistaken for downloading.
+func (d download) needsVerifyOnlyAlone() error {
+	if d.verifyOnly && (d.asJSON || d.events != nil || d.dryRun || d.listFilesOnly || d.stdout != "" || d.printDestination) {
+		return errors.New("--verify-only can't be used with --json, --events, --dry-run, --list-files-only, --stdout, or --print-destination")
+	}
+	return nil
+}
+
+// needsKnownFiles makes sure that every file named by --file is one of the solution's.
+func (d download) needsKnownFiles() error {
+	for _, only := range d.onlyFiles {
+		known := false
+		for _, sf := range d.payload.files() {
+			if sf.isNamed(only) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("'%s' is not one of the solution's files", only)
+		}
+	}
+	return nil
+}
+
+// needsValidOutputFormat ensures that the layout is one we know.
+func (d download) needsValidOutputFormat() error {
+	switch d.outputFormat {
+	case outputFormatNested, outputFormatFlat:
+		return nil
+	}
+	return fmt.Errorf("--output-format must be '%s' or '%s', not '%s'", outputFormatNested, outputFormatFlat, d.outputFormat)
+}
+
+// needsValidPathTemplate ensures that the exercise path template only uses
+// the placeholders we know, and stays within the workspace.
+func (d download) needsValidPathTemplate() error {
+	known := make(map[string]bool, len(pathPlaceholders))
+	for _, name := range pathPlaceholders {
+		known[name] = true
+	}
+	for _, match := range rgxPathPlaceholder.FindAllStringSubmatch(d.pathTemplate, -1) {
+		if !known[match[1]] {
+			return fmt.Errorf("exercisepathtemplate '%s' has an unknown placeholder '%s', use {%s}", d.pathTemplate, match[0], strings.Join(pathPlaceholders, "}, {"))
+		}
+	}
+	if strings.HasPrefix(filepath.ToSlash(d.pathTemplate), "/") || filepath.IsAbs(d.pathTemplate) {
+		return fmt.Errorf("exercisepathtemplate '%s' must be relative to the workspace", d.pathTemplate)
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(d.pathTemplate), "/") {
+		if segment == ".." {
+			return fmt.Errorf("exercisepathtemplate '%s' must not leave the workspace", d.pathTemplate)
+		}
+	}
+	return nil
+}
+
+// needsNonNegativeMaxRetries ensures that the retry limit is a number of retries,
+// or 0 to make a single attempt at each request.
+func (d download) needsNonNegativeMaxRetries() error {
+	if d.maxRetries < 0 {
+		return errors.New("--max-retries must not be negative")
+	}
+	return nil
+}
+
+// needsNonNegativeMaxRedirects ensures that the redirect limit is a number of redirects.
+func (d download) needsNonNegativeMaxRedirects() error {
+	if d.maxRedirects < 0 {
+		return errors.New("--max-redirects must not be negative")
+	}
+	return nil
+}
+
+// needsNonNegativeMaxFileSize ensures that the file size limit is a size, or 0 for no limit.
+func (d download) needsNonNegativeMaxFileSize() error {
+	if d.maxFileSize < 0 {
+		return errors.New("--max-file-size must not be negative")
+	}
+	return nil
+}
+
+// needsNonNegativeTimeoutPerFile ensures that the per-file timeout is a duration, or 0 for none.
+func (d download) needsNonNegativeTimeoutPerFile() error {
+	if d.timeoutPerFile < 0 {
+		return errors.New("--timeout-per-file must not be negative")
+	}
+	return nil
+}
+
+// needsWritableOutputDir checks that the output directory, or its closest
+// existing ancestor, is a directory that can be written to.
+func (d download) needsWritableOutputDir() error {
+	if d.outputDir == "" {
+		return nil
+	}
+
+	dir := d.outputDir
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("--output-dir: '%s' is not a directory", dir)
+			}
+			break
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+
+	f, err := ioutil.TempFile(dir, ".exercism-")
+	if err != nil {
+		return fmt.Errorf("--output-dir: '%s' is not writable", dir)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// needsWorkspace catches a mistyped workspace before the download creates it.
+// A missing workspace is only created inside an existing directory, and
+// only after confirmation when running interactively.
+func (d download) needsWorkspace() error {
+	if d.outputDir != "" || d.dryRun || d.listFilesOnly || d.stdout != "" || d.verifyOnly || d.compareUUID != "" {
+		return nil
+	}
+	if _, err := os.Stat(d.workspace); !os.IsNotExist(err) {
+		return nil
+	}
+
+	parent := filepath.Dir(d.workspace)
+	if _, err := os.Stat(parent); err != nil {
+		return fmt.Errorf("the workspace '%s' does not exist, and neither does '%s'; check the workspace in the user config", d.workspace, parent)
+	}
+	if d.nonInteractive {
+		return fmt.Errorf("the workspace '%s' does not exist", d.workspace)
+	}
+
+	// The question goes to Err, like the other prompts,
+	// since programs read Out for the destination or events.
+	if stdinIsTerminal() && !d.asJSON && d.events == nil {
+		fmt.Fprintf(Err, "\nThe workspace '%s' does not exist. Create it? [y]es, [n]o: ", d.workspace)
+		answer, err := bufio.NewReader(In).ReadString('\n')
+		// Without an answer there's nobody to ask, so carry on as if non-interactive.
+		if err != io.EOF || answer != "" {
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "y", "yes":
+				return nil
+			}
+			return fmt.Errorf("the workspace '%s' does not exist", d.workspace)
+		}
+	}
+	fmt.Fprintf(Err, "\nCreating the workspace '%s'\n", d.workspace)
+	return nil
+}
+
+// needsPromptsAllowed ensures that nothing asks whether to overwrite a file
+// when --non-interactive forbids asking.
+func (d download) needsPromptsAllowed() error {
+	prompts := d.interactive && !d.forceoverwrite && (d.collisions == "" || d.collisions == collisionsPrompt)
+	if d.nonInteractive && prompts {
+		return errors.New("--interactive and --rename-collision=prompt can't be used with --non-interactive")
+	}
+	return nil
+}
+
+// needsValidProxy checks that the proxy is an http, https, or socks5 URL.
+func (d download) needsValidProxy() error {
+	if d.proxy == "" {
+		return nil
+	}
+	proxy, err := d.proxyURL()
+	if err != nil {
+		return fmt.Errorf("--proxy: '%s' is not a valid URL", d.proxy)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("--proxy: '%s' must use http, https, or socks5", d.proxy)
+	}
+	if proxy.Host == "" {
+		return fmt.Errorf("--proxy: '%s' is missing a host", d.proxy)
+	}
+	return nil
+}
+
+// needsValidSolutionsPath checks that the solutions path can be appended to the API base URL.
+func (d download) needsValidSolutionsPath() error {
+	if !strings.HasPrefix(d.apisolutionspath, "/") {
+		return fmt.Errorf("the apisolutionspath '%s' in the user config must begin with a slash", d.apisolutionspath)
+	}
+	return nil
+}
+
 type downloadPayload struct {
 	Solution struct {
 		ID   string `json:"id"`
@@ -289,7 +3009,10 @@ type downloadPayload struct {
 		} `json:"exercise"`
 		FileDownloadBaseURL string   `json:"file_download_base_url"`
 		Files               []string `json:"files"`
-		Iteration           struct {
+		// FileContents optionally embeds the contents of files by name,
+		// base64-encoded, so that they don't need to be downloaded.
+		FileContents map[string][]byte `json:"file_contents,omitempty"`
+		Iteration    struct {
 			SubmittedAt *string `json:"submitted_at"`
 		}
 	} `json:"solution"`
@@ -300,6 +3023,25 @@ type downloadPayload struct {
 	} `json:"error,omitempty"`
 }
 
+// validate checks that the payload describes where to download its files from.
+// Files with inline contents don't need to be downloaded.
+func (dp downloadPayload) validate() error {
+	remote := 0
+	for _, file := range dp.Solution.Files {
+		if _, ok := dp.Solution.FileContents[file]; !ok {
+			remote++
+		}
+	}
+	if remote == 0 {
+		return nil
+	}
+	baseURL := dp.Solution.FileDownloadBaseURL
+	if u, err := netURL.Parse(baseURL); err != nil || !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("the API response has an invalid file download base URL: '%s'", baseURL)
+	}
+	return nil
+}
+
 func (dp downloadPayload) metadata() workspace.ExerciseMetadata {
 	return workspace.ExerciseMetadata{
 		AutoApprove:  dp.Solution.Exercise.AutoApprove,
@@ -321,6 +3063,7 @@ func (dp downloadPayload) files() []solutionFile {
 			baseURL: dp.Solution.FileDownloadBaseURL,
 			slug:    dp.Solution.Exercise.ID,
 		}
+		f.contents, f.inline = dp.Solution.FileContents[file]
 		fx = append(fx, f)
 	}
 	return fx
@@ -328,6 +3071,10 @@ func (dp downloadPayload) files() []solutionFile {
 
 type solutionFile struct {
 	path, baseURL, slug string
+
+	// inline files are embedded in the payload rather than downloaded
+	inline   bool
+	contents []byte
 }
 
 func (sf solutionFile) url() (string, error) {
@@ -340,17 +3087,14 @@ func (sf solutionFile) url() (string, error) {
 	return url.String(), nil
 }
 
-func (sf solutionFile) relativePath() string {
-	file := sf.path
+// isNamed tells whether name, as given on the command line, refers to the file.
+func (sf solutionFile) isNamed(name string) bool {
+	return filepath.ToSlash(sf.relativePath()) == filepath.ToSlash(filepath.Clean(name))
+}
 
-	// Work around a path bug due to an early design decision (later reversed) to
-	// allow numeric suffixes for exercise directories, letting people have
-	// multiple parallel versions of an exercise.
-	pattern := fmt.Sprintf(`\A.*[/\\]%s-\d*/`, sf.slug)
-	rgxNumericSuffix := regexp.MustCompile(pattern)
-	if rgxNumericSuffix.MatchString(sf.path) {
-		file = string(rgxNumericSuffix.ReplaceAll([]byte(sf.path), []byte("")))
-	}
+func (sf solutionFile) relativePath() string {
+	file := sanitizeLegacyNumericSuffixFilepath(sf.path, sf.slug)
+	file = stripWindowsVolume(file, sf.slug)
 
 	// Rewrite paths submitted with an older, buggy client where the Windows path is being treated as part of the filename.
 	file = strings.Replace(file, "\\", "/", -1)
@@ -358,12 +3102,226 @@ func (sf solutionFile) relativePath() string {
 	return filepath.FromSlash(file)
 }
 
+// sanitizeLegacyNumericSuffixFilepath works around a path bug due to an early
+// design decision (later reversed) to allow numeric suffixes for exercise
+// directories, letting people have multiple parallel versions of an exercise.
+// Only the first slug-<num> directory, and everything before it, is removed.
+func sanitizeLegacyNumericSuffixFilepath(file, slug string) string {
+	if slug == "" {
+		return file
+	}
+	pattern := fmt.Sprintf(`\A(?:.*?[/\\])?%s-\d+[/\\]`, regexp.QuoteMeta(slug))
+	rgxNumericSuffix := regexp.MustCompile(pattern)
+	return rgxNumericSuffix.ReplaceAllString(file, "")
+}
+
+var (
+	rgxWindowsVolume    = regexp.MustCompile(`\A(?:[a-zA-Z]:|[/\\]{2}[^/\\]+[/\\]+[^/\\]+)[/\\]*`)
+	rgxWindowsSeparator = regexp.MustCompile(`[/\\]`)
+)
+
+// stripWindowsVolume removes the drive letter or UNC share from an absolute
+// Windows path, as submitted by an older, buggy client.
+// What's left is taken from below the exercise directory if the path goes
+// through it, and otherwise from below the volume.
+func stripWindowsVolume(file, slug string) string {
+	loc := rgxWindowsVolume.FindStringIndex(file)
+	if loc == nil {
+		return file
+	}
+	file = file[loc[1]:]
+
+	parts := rgxWindowsSeparator.Split(file, -1)
+	for i, part := range parts[:len(parts)-1] {
+		if slug != "" && part == slug {
+			return strings.Join(parts[i+1:], "/")
+		}
+	}
+	return file
+}
+
+// stdinIsTerminal reports whether In is attached to an interactive terminal.
+var stdinIsTerminal = func() bool {
+	f, ok := In.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// collisionResolver decides whether a downloaded file may replace an existing one.
+// Prompts are serialized so that concurrent downloads don't interleave questions.
+type collisionResolver struct {
+	mu          sync.Mutex
+	interactive bool
+	// suffix keeps both files, writing the downloaded one next to the existing one.
+	suffix bool
+	in     *bufio.Reader
+}
+
+// writePath gives the path to write the downloaded contents of the file at
+// path to, or an empty path if the file is skipped. That is path itself,
+// unless the resolver keeps both files of a collision, in which case it's the
+// first free path with a numeric suffix, as file (1).go for file.go.
+// A suffixed copy that already has the contents is kept instead.
+func (c *collisionResolver) writePath(path string, contents []byte) (string, error) {
+	if !c.suffix {
+		ok, err := c.shouldWrite(path, contents)
+		if err != nil || !ok {
+			return "", err
+		}
+		return path, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	if filepath.Base(path) == ext {
+		// A dotfile, such as .gitignore, has no extension to keep.
+		base, ext = path, ""
+	}
+	candidate := path
+	for n := 1; ; n++ {
+		existing, err := ioutil.ReadFile(candidate)
+		if os.IsNotExist(err) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if bytes.Equal(existing, contents) {
+			return "", nil
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+	}
+}
+
+// shouldWrite compares the existing file at path with the downloaded contents.
+// Identical files are skipped silently. When the contents differ the person is
+// asked whether to overwrite, or the file is skipped with a warning if the
+// session is not interactive.
+func (c *collisionResolver) shouldWrite(path string, contents []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	existing, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(existing, contents) {
+		return false, nil
+	}
+	if !c.interactive {
+		warnf("Skipping '%s', it has local changes.", path)
+		return false, nil
+	}
+
+	for {
+		fmt.Fprintf(Err, "\n'%s' has local changes. Overwrite? [y]es, [n]o, [d]iff: ", path)
+		answer, err := c.in.ReadString('\n')
+		if err != nil && answer == "" {
+			if err == io.EOF {
+				return false, nil
+			}
+			return false, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "d", "diff":
+			diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				A:        difflib.SplitLines(string(existing)),
+				B:        difflib.SplitLines(string(contents)),
+				FromFile: path,
+				ToFile:   path + " (downloaded)",
+				Context:  3,
+			})
+			if err != nil {
+				return false, err
+			}
+			fmt.Fprint(Err, diff)
+		}
+	}
+}
+
 func setupDownloadFlags(flags *pflag.FlagSet) {
+	setupUserConfigFlags(flags)
+	setupClientFlags(flags)
 	flags.StringP("uuid", "u", "", "the solution UUID")
 	flags.StringP("track", "t", "", "the track ID")
 	flags.StringP("exercise", "e", "", "the exercise slug")
 	flags.StringP("team", "T", "", "the team slug")
+	flags.StringP("url", "", "", "the solution or exercise URL from the website")
+	flags.BoolP("latest", "", false, "download the latest solution to the exercise, which is the default without --uuid")
+	flags.StringP("from-file", "", "", "use a previously captured solution payload instead of the API")
+	flags.BoolP("no-cache", "", false, "always ask the API for the solution, instead of reusing a recent answer")
+	flags.DurationP("cache-ttl", "", defaultPayloadCacheTTL, "how long to reuse the API's answer about a solution (0 to always ask)")
+	flags.BoolP("personal", "", false, "download your personal solution rather than a team solution")
+	flags.StringP("output-dir", "o", "", "download to this directory instead of the workspace")
+	flags.StringP("output-format", "", outputFormatNested, "layout of the workspace: nested puts team and other users' solutions in their own directories, flat doesn't")
+	flags.BoolP("nest-teams", "", true, "put team solutions in teams/<team> when nested")
+	flags.BoolP("nest-users", "", true, "put other users' solutions in users/<handle> when nested")
+	flags.BoolP("no-nested", "", false, "download to the workspace root, even for team and other users' solutions (same as --output-format=flat)")
 	flags.BoolP("force", "F", false, "overwrite existing exercise directory")
+	flags.BoolP("interactive", "i", false, "ask before overwriting files with local changes")
+	flags.BoolP("non-interactive", "", false, "never ask a question, failing where one would be asked")
+	flags.StringP("rename-collision", "", "", "how to handle files with local changes: skip, overwrite, suffix (keep both, as file (1).go), or prompt")
+	flags.BoolP("resume", "", false, "only download files missing from a partial download")
+	flags.BoolP("skip-unchanged", "", false, "don't rewrite files whose checksum matches the server's ETag")
+	flags.BoolP("keep-empty", "", false, "write empty files instead of skipping them")
+	flags.BoolP("reject-html", "", false, "refuse to write a file if the server sends an HTML page instead")
+	flags.StringSliceP("file", "", nil, "only download this solution file, can be repeated")
+	flags.IntP("concurrency", "", defaultConcurrency, "number of files to download at a time")
+	flags.DurationP("timeout-per-file", "", 0, "give up on a file that takes longer than this to download (0 for no limit)")
+	flags.Int64P("max-file-size", "", 0, "refuse to download a file larger than this many bytes (0 for no limit)")
+	flags.StringSliceP("executable-ext", "", defaultExecutableExts, "extensions of files to make executable")
+	flags.StringArrayP("rename", "", nil, "write files with one extension under another, as in .example=.go, can be repeated")
+	flags.BoolP("strict", "", false, "fail if any file cannot be downloaded, or if there are none")
+	flags.BoolP("dry-run", "", false, "list where files would be written without writing them")
+	flags.BoolP("verify-only", "", false, "compare the local files with the solution instead of downloading it")
+	flags.StringP("compare-uuid", "", "", "print a diff from the --uuid solution to this one, instead of downloading either")
+	flags.BoolP("print-destination", "", false, "print only the exercise directory; with --dry-run, nothing is downloaded")
+	flags.BoolP("with-instructions", "", false, "also download the exercise instructions as a README")
+	flags.BoolP("write-url", "", false, "also write the solution's URL on the website to "+solutionURLFilename)
+	flags.StringP("hook", "", "", "shell command to run in the exercise directory after downloading")
+	flags.BoolP("git-commit", "", false, "commit the downloaded files, if the workspace is a git repository")
+	flags.BoolP("list-files-only", "", false, "list the solution's files without downloading them")
+	flags.StringP("stdout", "", "", "write this one solution file to stdout instead of downloading the exercise")
+	flags.BoolP("json", "", false, "print a JSON summary instead of human-readable output")
+	flags.BoolP("events", "", false, "stream the progress as JSON lines instead of human-readable output")
+}
+
+// setupUserConfigFlags adds the flags that pick the user config to read.
+func setupUserConfigFlags(flags *pflag.FlagSet) {
+	flags.StringP("config", "", "", "read the user config from this file instead of the default location")
+	flags.StringP("profile", "", "", "use the settings of this profile from the user config")
+}
+
+// setupClientFlags adds the flags for the connection to the API, which every
+// command that talks to the API the way download does accepts.
+func setupClientFlags(flags *pflag.FlagSet) {
+	flags.StringP("token", "", "", "API token to use instead of the one in the user config")
+	flags.StringP("proxy", "", "", "proxy URL to send requests through (http, https, or socks5)")
+	flags.StringP("user-agent", "", "", "User-Agent header to send instead of the CLI's own")
+	flags.StringP("cacert", "", "", "PEM file of an extra certificate authority to trust")
+	flags.StringP("clientcert", "", "", "PEM file of a client certificate to present")
+	flags.StringP("clientkey", "", "", "PEM file of the client certificate's private key")
+	flags.IntP("max-redirects", "", defaultMaxRedirects, "number of redirects to follow when downloading a file")
+	flags.IntP("max-retries", "", defaultMaxRetries, "number of times to retry a request that fails transiently (0 to fail on the first error)")
+	flags.DurationP("retry-delay", "", api.DefaultRetryBaseDelay, "time to wait before the first retry, doubling for each one after")
+	flags.DurationP("max-retry-wait", "", api.DefaultRetryMaxDelay, "longest time to wait before retrying a request")
 }
 
 func init() {
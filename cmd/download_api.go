@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// DownloadOptions describe which solution to download, and where to.
+// Give either the UUID of a solution, or the Exercise, optionally with
+// its Track and Team. A URL from the website can stand in for either.
+type DownloadOptions struct {
+	UUID     string
+	Exercise string
+	Track    string
+	Team     string
+	URL      string
+	// Personal downloads your own solution rather than the team's.
+	Personal bool
+	// OutputDir overrides the directory in the workspace.
+	OutputDir string
+	// Force overwrites an existing exercise directory.
+	Force bool
+	// WithInstructions also downloads the exercise instructions as a README.
+	WithInstructions bool
+}
+
+// Download downloads a solution the way the download command does, for
+// programs that embed the CLI. The user config needs the token, the
+// workspace, and the apibaseurl, as written by the configure command.
+// Nothing is written to Out, though warnings still go to Err, and nothing
+// is asked: a download that would need to ask, such as one into a missing
+// workspace, fails instead.
+func Download(ctx context.Context, cfg *viper.Viper, opts DownloadOptions) (DownloadResult, error) {
+	start := time.Now()
+	if err := validateUserConfig(cfg); err != nil {
+		return DownloadResult{}, err
+	}
+
+	d, err := newDownloadFromOptions(ctx, opts, cfg)
+	if err != nil {
+		return DownloadResult{}, redactToken(err, cfg.GetString("token"))
+	}
+	result, err := d.save(ctx, start)
+	return result, redactToken(err, cfg.GetString("token"))
+}
@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDownloadAPI(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "contents of %s", r.URL.Path)
+	}
+	ts := fakeSolutionServer(handler, "file.txt", "subdir/nested.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-api")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	cfg := viper.New()
+	cfg.Set("token", "abc123")
+	cfg.Set("workspace", tmpDir)
+	cfg.Set("apibaseurl", ts.URL)
+
+	result, err := Download(context.Background(), cfg, DownloadOptions{Exercise: "bogus-exercise"})
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	assert.Equal(t, "bogus-id", result.ID)
+	assert.Equal(t, "bogus-track", result.Track)
+	assert.Equal(t, "bogus-exercise", result.Exercise)
+	assert.Equal(t, dir, result.Destination)
+	assert.Equal(t, []string{filepath.Join(dir, "file.txt"), filepath.Join(dir, "subdir", "nested.txt")}, result.Files)
+	assert.Equal(t, int64(len("contents of /files/file.txt")+len("contents of /files/subdir/nested.txt")), result.Bytes)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "subdir", "nested.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "contents of /files/subdir/nested.txt", string(b))
+	assert.Equal(t, "", Out.(*bytes.Buffer).String())
+
+	// Downloading it again changes nothing.
+	result, err = Download(context.Background(), cfg, DownloadOptions{Exercise: "bogus-exercise"})
+	assert.NoError(t, err)
+	assert.True(t, result.UpToDate)
+	assert.Empty(t, result.Files)
+
+	// With local changes, the directory is only replaced when forced.
+	err = ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("local changes"), os.FileMode(0644))
+	assert.NoError(t, err)
+	_, err = Download(context.Background(), cfg, DownloadOptions{Exercise: "bogus-exercise"})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "already exists", err.Error())
+	}
+	_, err = Download(context.Background(), cfg, DownloadOptions{Exercise: "bogus-exercise", Force: true})
+	assert.NoError(t, err)
+}
+
+func TestDownloadAPIOptions(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	var requested []string
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/solutions/bogus-id", func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	})
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-api-options")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+	outputDir := filepath.Join(tmpDir, "elsewhere")
+
+	cfg := viper.New()
+	cfg.Set("token", "abc123")
+	cfg.Set("workspace", tmpDir)
+	cfg.Set("apibaseurl", ts.URL)
+
+	result, err := Download(context.Background(), cfg, DownloadOptions{
+		URL:       ts.URL + "/solutions/bogus-id",
+		OutputDir: outputDir,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/solutions/bogus-id"}, requested)
+	assert.Equal(t, outputDir, result.Destination)
+	assert.Equal(t, []string{filepath.Join(outputDir, "file.txt")}, result.Files)
+	assert.Equal(t, "", Out.(*bytes.Buffer).String())
+
+	// The URL picks the solution, so it can't be given along with another.
+	_, err = Download(context.Background(), cfg, DownloadOptions{UUID: "bogus-id", URL: ts.URL + "/solutions/bogus-id"})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "cannot be used with", err.Error())
+	}
+}
+
+func TestDownloadAPIErrors(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		config map[string]string
+		opts   DownloadOptions
+		kind   error
+		err    string
+	}{
+		{
+			desc:   "without a token",
+			config: map[string]string{"workspace": "/tmp", "apibaseurl": "http://example.com"},
+			opts:   DownloadOptions{Exercise: "bogus-exercise"},
+			kind:   ErrMissingConfig,
+		},
+		{
+			desc:   "without a solution",
+			config: map[string]string{"token": "abc123", "workspace": "/tmp", "apibaseurl": "http://example.com"},
+			opts:   DownloadOptions{},
+			err:    "need an --exercise name or a solution --uuid",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := viper.New()
+			for key, value := range tc.config {
+				cfg.Set(key, value)
+			}
+
+			_, err := Download(context.Background(), cfg, tc.opts)
+			if assert.Error(t, err) {
+				if tc.kind != nil {
+					assert.True(t, errors.Is(err, tc.kind))
+				}
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestDownloadAPINeverAsks(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	// Even at a terminal, the answer isn't read.
+	oldIn, oldIsTerminal := In, stdinIsTerminal
+	defer func() { In, stdinIsTerminal = oldIn, oldIsTerminal }()
+	stdinIsTerminal = func() bool { return true }
+	in := strings.NewReader("y\n")
+	In = in
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-api")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+	workspace := filepath.Join(tmpDir, "workspace")
+
+	cfg := viper.New()
+	cfg.Set("token", "abc123")
+	cfg.Set("workspace", workspace)
+	cfg.Set("apibaseurl", ts.URL)
+
+	_, err = Download(context.Background(), cfg, DownloadOptions{Exercise: "bogus-exercise"})
+	if assert.Error(t, err) {
+		assert.Equal(t, fmt.Sprintf("the workspace '%s' does not exist", workspace), err.Error())
+	}
+	assert.Equal(t, "", Out.(*bytes.Buffer).String())
+	assert.Equal(t, len("y\n"), in.Len())
+
+	_, err = os.Stat(workspace)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadNonInteractiveWithPrompts(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		flags map[string]string
+		err   bool
+	}{
+		{desc: "--interactive", flags: map[string]string{"interactive": "true"}, err: true},
+		{desc: "--rename-collision=prompt", flags: map[string]string{"rename-collision": "prompt"}, err: true},
+		{desc: "--rename-collision=suffix", flags: map[string]string{"rename-collision": "suffix"}},
+		{desc: "--interactive --force", flags: map[string]string{"interactive": "true", "force": "true"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "contents")
+			}, "file.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-non-interactive")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("non-interactive", "true")
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if !tc.err {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Equal(t, "--interactive and --rename-collision=prompt can't be used with --non-interactive", err.Error())
+			}
+		})
+	}
+}
@@ -1,18 +1,42 @@
 package cmd
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	netURL "net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/exercism/cli/api"
 	"github.com/exercism/cli/config"
+	"github.com/exercism/cli/debug"
 	"github.com/exercism/cli/workspace"
+	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
@@ -23,11 +47,12 @@ func TestDownloadWithoutToken(t *testing.T) {
 		UserViperConfig: viper.New(),
 	}
 
-	err := runDownload(cfg, pflag.NewFlagSet("fake", pflag.PanicOnError), []string{})
+	err := runDownload(context.Background(), cfg, pflag.NewFlagSet("fake", pflag.PanicOnError), []string{})
 	if assert.Error(t, err) {
 		assert.Regexp(t, "Welcome to Exercism", err.Error())
 		// It uses the default base API url to infer the host
 		assert.Regexp(t, "exercism.io/my/settings", err.Error())
+		assert.True(t, errors.Is(err, ErrMissingConfig))
 	}
 }
 
@@ -38,7 +63,7 @@ func TestDownloadWithoutWorkspace(t *testing.T) {
 		UserViperConfig: v,
 	}
 
-	err := runDownload(cfg, pflag.NewFlagSet("fake", pflag.PanicOnError), []string{})
+	err := runDownload(context.Background(), cfg, pflag.NewFlagSet("fake", pflag.PanicOnError), []string{})
 	if assert.Error(t, err) {
 		assert.Regexp(t, "re-run the configure", err.Error())
 	}
@@ -52,9 +77,10 @@ func TestDownloadWithoutBaseURL(t *testing.T) {
 		UserViperConfig: v,
 	}
 
-	err := runDownload(cfg, pflag.NewFlagSet("fake", pflag.PanicOnError), []string{})
+	err := runDownload(context.Background(), cfg, pflag.NewFlagSet("fake", pflag.PanicOnError), []string{})
 	if assert.Error(t, err) {
 		assert.Regexp(t, "re-run the configure", err.Error())
+		assert.True(t, errors.Is(err, ErrMissingConfig))
 	}
 }
 
@@ -71,7 +97,7 @@ func TestDownloadWithoutFlags(t *testing.T) {
 	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
 	setupDownloadFlags(flags)
 
-	err := runDownload(cfg, flags, []string{})
+	err := runDownload(context.Background(), cfg, flags, []string{})
 	if assert.Error(t, err) {
 		assert.Regexp(t, "need an --exercise name or a solution --uuid", err.Error())
 	}
@@ -79,7 +105,7 @@ func TestDownloadWithoutFlags(t *testing.T) {
 
 func TestSolutionFile(t *testing.T) {
 	testCases := []struct {
-		name, file, expectedPath, expectedURL string
+		name, file, slug, expectedPath, expectedURL string
 	}{
 		{
 			name:         "filename with special character",
@@ -105,6 +131,13 @@ func TestSolutionFile(t *testing.T) {
 			expectedPath: fmt.Sprintf("%[1]cbackslashes%[1]cin-path.txt", os.PathSeparator),
 			expectedURL:  "http://www.example.com/%5Cbackslashes%5Cin-path.txt",
 		},
+		{
+			name:         "path with a drive letter",
+			file:         "C:\\Users\\alice\\bogus-exercise\\drive.txt",
+			slug:         "bogus-exercise",
+			expectedPath: "drive.txt",
+			expectedURL:  "http://www.example.com/C:%5CUsers%5Calice%5Cbogus-exercise%5Cdrive.txt",
+		},
 		{
 			name:         "path with a numeric suffix",
 			file:         "/bogus-exercise-12345/numeric.txt",
@@ -117,6 +150,7 @@ func TestSolutionFile(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			sf := solutionFile{
 				path:    tc.file,
+				slug:    tc.slug,
 				baseURL: "http://www.example.com/",
 			}
 
@@ -136,6 +170,117 @@ func TestSolutionFile(t *testing.T) {
 	}
 }
 
+func TestSanitizeLegacyNumericSuffixFilepath(t *testing.T) {
+	testCases := []struct {
+		desc, file, expected string
+	}{
+		{
+			desc:     "no numeric suffix",
+			file:     "/two-fer/two_fer.go",
+			expected: "/two-fer/two_fer.go",
+		},
+		{
+			desc:     "numeric suffix",
+			file:     "/home/alice/exercism/go/two-fer-1/two_fer.go",
+			expected: "two_fer.go",
+		},
+		{
+			desc:     "numeric suffix at the start",
+			file:     "two-fer-1/src/two-fer/helper.go",
+			expected: "src/two-fer/helper.go",
+		},
+		{
+			desc:     "slug repeated below the numeric suffix",
+			file:     "/go/two-fer-1/src/two-fer-2/helper.go",
+			expected: "src/two-fer-2/helper.go",
+		},
+		{
+			desc:     "slug as part of a longer directory name",
+			file:     "/go/my-two-fer-1/helper.go",
+			expected: "/go/my-two-fer-1/helper.go",
+		},
+		{
+			desc:     "slug without digits",
+			file:     "/go/two-fer-/helper.go",
+			expected: "/go/two-fer-/helper.go",
+		},
+		{
+			desc:     "Windows separators",
+			file:     "C:\\Users\\alice\\two-fer-3\\src\\two-fer-4\\helper.go",
+			expected: "src\\two-fer-4\\helper.go",
+		},
+		{
+			desc:     "mixed separators",
+			file:     "C:\\Users\\alice/two-fer-3\\helper.go",
+			expected: "helper.go",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sanitizeLegacyNumericSuffixFilepath(tc.file, "two-fer"))
+		})
+	}
+}
+
+func TestStripWindowsVolume(t *testing.T) {
+	testCases := []struct {
+		desc, file, expected string
+	}{
+		{
+			desc:     "relative path",
+			file:     "src\\two_fer.go",
+			expected: "src\\two_fer.go",
+		},
+		{
+			desc:     "path with a leading slash",
+			file:     "/two-fer/two_fer.go",
+			expected: "/two-fer/two_fer.go",
+		},
+		{
+			desc:     "drive letter through the exercise directory",
+			file:     "C:\\Users\\alice\\exercism\\go\\two-fer\\src\\two_fer.go",
+			expected: "src/two_fer.go",
+		},
+		{
+			desc:     "lowercase drive letter with forward slashes",
+			file:     "c:/Users/alice/exercism/go/two-fer/two_fer.go",
+			expected: "two_fer.go",
+		},
+		{
+			desc:     "drive letter outside of an exercise directory",
+			file:     "D:\\code\\two_fer.go",
+			expected: "code\\two_fer.go",
+		},
+		{
+			desc:     "drive letter without a separator",
+			file:     "C:two_fer.go",
+			expected: "two_fer.go",
+		},
+		{
+			desc:     "file named like the exercise",
+			file:     "C:\\Users\\alice\\two-fer",
+			expected: "Users\\alice\\two-fer",
+		},
+		{
+			desc:     "UNC path through the exercise directory",
+			file:     "\\\\server\\share\\exercism\\go\\two-fer\\two_fer.go",
+			expected: "two_fer.go",
+		},
+		{
+			desc:     "UNC path with forward slashes",
+			file:     "//server/share/code/two_fer.go",
+			expected: "code/two_fer.go",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, stripWindowsVolume(tc.file, "two-fer"))
+		})
+	}
+}
+
 func TestDownload(t *testing.T) {
 	co := newCapturedOutput()
 	co.override()
@@ -190,7 +335,7 @@ func TestDownload(t *testing.T) {
 			flags.Set(name, value)
 		}
 
-		err = runDownload(cfg, flags, []string{})
+		err = runDownload(context.Background(), cfg, flags, []string{})
 		assert.NoError(t, err)
 
 		targetDir := filepath.Join(tmpDir, tc.expectedDir)
@@ -206,6 +351,10 @@ func TestDownload(t *testing.T) {
 		assert.Equal(t, "bogus-track", metadata.Track)
 		assert.Equal(t, "bogus-exercise", metadata.ExerciseSlug)
 		assert.Equal(t, tc.requester, metadata.IsRequester)
+		assert.Equal(t, ts.URL, metadata.APIBaseURL)
+		if assert.NotNil(t, metadata.DownloadedAt) {
+			assert.WithinDuration(t, time.Now(), *metadata.DownloadedAt, time.Minute)
+		}
 	}
 }
 
@@ -253,7 +402,7 @@ func TestDownloadToExistingDirectory(t *testing.T) {
 			flags.Set(name, value)
 		}
 
-		err = runDownload(cfg, flags, []string{})
+		err = runDownload(context.Background(), cfg, flags, []string{})
 
 		if assert.Error(t, err) {
 			assert.Regexp(t, "directory '.+' already exists", err.Error())
@@ -261,6 +410,77 @@ func TestDownloadToExistingDirectory(t *testing.T) {
 	}
 }
 
+func TestDownloadAlreadyUpToDate(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "contents of %s", r.URL.Path)
+	}
+	ts := fakeSolutionServer(handler, "file.txt", "subdir/nested.txt")
+	defer ts.Close()
+
+	testCases := []struct {
+		desc  string
+		files map[string]string
+		err   string
+	}{
+		{
+			desc: "every file present",
+			files: map[string]string{
+				"file.txt":          "contents of /files/file.txt",
+				"subdir/nested.txt": "contents of /files/subdir/nested.txt",
+			},
+		},
+		{
+			desc:  "some files present",
+			files: map[string]string{"file.txt": "contents of /files/file.txt"},
+			err:   "directory '.+' already exists",
+		},
+		{
+			desc: "a file with local changes",
+			files: map[string]string{
+				"file.txt":          "contents of /files/file.txt",
+				"subdir/nested.txt": "local changes",
+			},
+			err: "directory '.+' already exists",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			tmpDir, err := ioutil.TempDir("", "download-up-to-date")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			for name, contents := range tc.files {
+				path := filepath.Join(dir, filepath.FromSlash(name))
+				err = os.MkdirAll(filepath.Dir(path), os.FileMode(0755))
+				assert.NoError(t, err)
+				err = ioutil.WriteFile(path, []byte(contents), os.FileMode(0644))
+				assert.NoError(t, err)
+			}
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Regexp(t, tc.err, err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "Already up to date\n", Out.(*bytes.Buffer).String())
+		})
+	}
+}
+
 func TestDownloadToExistingDirectoryWithForce(t *testing.T) {
 	co := newCapturedOutput()
 	co.override()
@@ -285,7 +505,14 @@ func TestDownloadToExistingDirectoryWithForce(t *testing.T) {
 		defer os.RemoveAll(tmpDir)
 		assert.NoError(t, err)
 
-		err = os.MkdirAll(filepath.Join(tmpDir, tc.exerciseDir), os.FileMode(0755))
+		dir := filepath.Join(tmpDir, tc.exerciseDir)
+		err = os.MkdirAll(dir, os.FileMode(0755))
+		assert.NoError(t, err)
+
+		err = ioutil.WriteFile(filepath.Join(dir, "file-1.txt"), []byte("stale"), os.FileMode(0644))
+		assert.NoError(t, err)
+		stale := &workspace.ExerciseMetadata{ID: "stale-id", Track: "bogus-track", ExerciseSlug: "bogus-exercise"}
+		err = stale.Write(dir)
 		assert.NoError(t, err)
 
 		ts := fakeDownloadServer("true", "")
@@ -306,106 +533,5783 @@ func TestDownloadToExistingDirectoryWithForce(t *testing.T) {
 		}
 		flags.Set("force", "true")
 
-		err = runDownload(cfg, flags, []string{})
+		err = runDownload(context.Background(), cfg, flags, []string{})
+		assert.NoError(t, err)
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "file-1.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "this is file 1", string(b))
+
+		metadata, err := workspace.NewExerciseMetadata(dir)
 		assert.NoError(t, err)
+		assert.Equal(t, "bogus-id", metadata.ID)
 	}
 }
 
-func fakeDownloadServer(requestor, teamSlug string) *httptest.Server {
-	mux := http.NewServeMux()
-	server := httptest.NewServer(mux)
+func TestDownloadInteractiveCollision(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
 
-	mux.HandleFunc("/file-1.txt", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "this is file 1")
-	})
+	oldIn, oldIsTerminal := In, stdinIsTerminal
+	defer func() { In, stdinIsTerminal = oldIn, oldIsTerminal }()
+	stdinIsTerminal = func() bool { return true }
 
-	mux.HandleFunc("/subdir/file-2.txt", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "this is file 2")
-	})
+	testCases := []struct {
+		desc, existing, answer, expected string
+		prompted                         bool
+	}{
+		{
+			desc:     "identical files are skipped silently",
+			existing: "this is file 1",
+			expected: "this is file 1",
+		},
+		{
+			desc:     "differing file with yes is overwritten",
+			existing: "my changes",
+			answer:   "y\n",
+			expected: "this is file 1",
+			prompted: true,
+		},
+		{
+			desc:     "differing file with no is kept",
+			existing: "my changes",
+			answer:   "n\n",
+			expected: "my changes",
+			prompted: true,
+		},
+	}
 
-	mux.HandleFunc("/file-3.txt", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprint(w, "")
-	})
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "download-interactive")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
 
-	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
-		team := "null"
-		if teamSlug := r.FormValue("team_id"); teamSlug != "" {
-			team = fmt.Sprintf(`{"name": "Bogus Team", "slug": "%s"}`, teamSlug)
-		}
-		payloadBody := fmt.Sprintf(payloadTemplate, requestor, team, server.URL+"/")
-		fmt.Fprint(w, payloadBody)
-	})
-	mux.HandleFunc("/solutions/bogus-id", func(w http.ResponseWriter, r *http.Request) {
-		payloadBody := fmt.Sprintf(payloadTemplate, requestor, "null", server.URL+"/")
-		fmt.Fprint(w, payloadBody)
-	})
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			err = os.MkdirAll(dir, os.FileMode(0755))
+			assert.NoError(t, err)
+			err = ioutil.WriteFile(filepath.Join(dir, "file-1.txt"), []byte(tc.existing), os.FileMode(0644))
+			assert.NoError(t, err)
 
-	return server
+			In = strings.NewReader(tc.answer)
+			errOut := &bytes.Buffer{}
+			Err = errOut
+
+			ts := fakeDownloadServer("true", "")
+			defer ts.Close()
+
+			v := viper.New()
+			v.Set("workspace", tmpDir)
+			v.Set("apibaseurl", ts.URL)
+			v.Set("token", "abc123")
+
+			cfg := config.Config{
+				UserViperConfig: v,
+			}
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("interactive", "true")
+
+			err = runDownload(context.Background(), cfg, flags, []string{})
+			assert.NoError(t, err)
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, "file-1.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(b))
+			assert.Equal(t, tc.prompted, strings.Contains(errOut.String(), "Overwrite?"))
+		})
+	}
 }
 
-func assertDownloadedCorrectFiles(t *testing.T, targetDir string) {
-	expectedFiles := []struct {
+func TestCollisionResolverNonInteractive(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+	errOut := &bytes.Buffer{}
+	Err = errOut
+
+	tmpDir, err := ioutil.TempDir("", "collision-resolver")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	path := filepath.Join(tmpDir, "file.txt")
+	err = ioutil.WriteFile(path, []byte("mine"), os.FileMode(0644))
+	assert.NoError(t, err)
+
+	resolver := collisionResolver{interactive: false}
+	ok, err := resolver.shouldWrite(path, []byte("theirs"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Regexp(t, "Skipping", errOut.String())
+}
+
+func TestDownloadRenameCollision(t *testing.T) {
+	testCases := []struct {
 		desc     string
-		path     string
-		contents string
+		mode     string
+		existing map[string]string
+		expected map[string]string
 	}{
 		{
-			desc:     "a file in the exercise root directory",
-			path:     filepath.Join(targetDir, "bogus-track", "bogus-exercise", "file-1.txt"),
-			contents: "this is file 1",
+			desc:     "skip keeps the local copy",
+			mode:     "skip",
+			existing: map[string]string{"leap.go": "mine"},
+			expected: map[string]string{"leap.go": "mine"},
 		},
 		{
-			desc:     "a file in a subdirectory",
-			path:     filepath.Join(targetDir, "bogus-track", "bogus-exercise", "subdir", "file-2.txt"),
-			contents: "this is file 2",
+			desc:     "overwrite replaces the local copy",
+			mode:     "overwrite",
+			existing: map[string]string{"leap.go": "mine"},
+			expected: map[string]string{"leap.go": "downloaded"},
+		},
+		{
+			desc:     "suffix keeps both",
+			mode:     "suffix",
+			existing: map[string]string{"leap.go": "mine"},
+			expected: map[string]string{"leap.go": "mine", "leap (1).go": "downloaded"},
+		},
+		{
+			desc:     "suffix takes the first free suffix",
+			mode:     "suffix",
+			existing: map[string]string{"leap.go": "mine", "leap (1).go": "older", "leap (2).go": "old"},
+			expected: map[string]string{"leap.go": "mine", "leap (1).go": "older", "leap (2).go": "old", "leap (3).go": "downloaded"},
+		},
+		{
+			desc:     "suffix doesn't copy an identical file again",
+			mode:     "suffix",
+			existing: map[string]string{"leap.go": "mine", "leap (1).go": "downloaded"},
+			expected: map[string]string{"leap.go": "mine", "leap (1).go": "downloaded"},
+		},
+		{
+			desc:     "suffix leaves an identical file alone",
+			mode:     "suffix",
+			existing: map[string]string{"leap.go": "downloaded"},
+			expected: map[string]string{"leap.go": "downloaded"},
+		},
+		{
+			desc:     "suffix writes a missing file in place",
+			mode:     "suffix",
+			existing: map[string]string{"notes.txt": "mine"},
+			expected: map[string]string{"notes.txt": "mine", "leap.go": "downloaded"},
 		},
 	}
 
-	for _, file := range expectedFiles {
-		t.Run(file.desc, func(t *testing.T) {
-			b, err := ioutil.ReadFile(file.path)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "downloaded")
+			}, "leap.go")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-rename-collision")
+			defer os.RemoveAll(tmpDir)
 			assert.NoError(t, err)
-			assert.Equal(t, file.contents, string(b))
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			err = os.MkdirAll(dir, os.FileMode(0755))
+			assert.NoError(t, err)
+			for name, contents := range tc.existing {
+				err = ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), os.FileMode(0644))
+				assert.NoError(t, err)
+			}
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("rename-collision", tc.mode)
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			files := map[string]string{}
+			infos, err := ioutil.ReadDir(dir)
+			assert.NoError(t, err)
+			for _, info := range infos {
+				if info.IsDir() {
+					continue
+				}
+				b, err := ioutil.ReadFile(filepath.Join(dir, info.Name()))
+				assert.NoError(t, err)
+				files[info.Name()] = string(b)
+			}
+			assert.Equal(t, tc.expected, files)
 		})
 	}
+}
 
-	path := filepath.Join(targetDir, "bogus-track", "bogus-exercise", "file-3.txt")
-	_, err := os.Lstat(path)
-	assert.True(t, os.IsNotExist(err), "It should not write the file if empty.")
+func TestCollisionResolverSuffixDotfile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "collision-suffix")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	path := filepath.Join(tmpDir, ".gitignore")
+	err = ioutil.WriteFile(path, []byte("mine"), os.FileMode(0644))
+	assert.NoError(t, err)
+
+	resolver := collisionResolver{suffix: true}
+	suffixed, err := resolver.writePath(path, []byte("theirs"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, ".gitignore (1)"), suffixed)
 }
 
-func TestDownloadError(t *testing.T) {
-	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusBadRequest)
-		fmt.Fprintf(w, `{"error": {"type": "error", "message": "test error"}}`)
-	})
+func TestDownloadInvalidRenameCollision(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		flags map[string]string
+		err   string
+	}{
+		{
+			desc:  "unknown mode",
+			flags: map[string]string{"rename-collision": "merge"},
+			err:   "--rename-collision must be one of 'skip', 'overwrite', 'suffix', or 'prompt', not 'merge'",
+		},
+		{
+			desc:  "with --force",
+			flags: map[string]string{"rename-collision": "suffix", "force": "true"},
+			err:   "--rename-collision can't be used with --force or --interactive",
+		},
+		{
+			desc:  "with --interactive",
+			flags: map[string]string{"rename-collision": "skip", "interactive": "true"},
+			err:   "--rename-collision can't be used with --force or --interactive",
+		},
+	}
 
-	ts := httptest.NewServer(handler)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			err := runDownload(context.Background(), fakeDownloadConfig(os.TempDir(), "http://example.com"), flags, []string{})
+			if assert.Error(t, err) {
+				assert.Equal(t, tc.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestDownloadConcurrency(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+		fmt.Fprintf(w, "contents of %s", filepath.Base(r.URL.Path))
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}
+
+	files := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt", "f.txt"}
+	ts := fakeSolutionServer(handler, files...)
 	defer ts.Close()
 
-	tmpDir, err := ioutil.TempDir("", "submit-err-tmp-dir")
+	tmpDir, err := ioutil.TempDir("", "download-concurrency")
 	defer os.RemoveAll(tmpDir)
 	assert.NoError(t, err)
 
-	v := viper.New()
-	v.Set("token", "abc123")
-	v.Set("workspace", tmpDir)
-	v.Set("apibaseurl", ts.URL)
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("concurrency", "2")
 
-	cfg := config.Config{
-		Persister:       config.InMemoryPersister{},
-		UserViperConfig: v,
-		DefaultBaseURL:  "http://example.com",
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	assert.True(t, maxInFlight <= 2, "expected at most 2 concurrent requests, got %d", maxInFlight)
+	for _, file := range files {
+		b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", file))
+		assert.NoError(t, err)
+		assert.Equal(t, "contents of "+file, string(b))
 	}
+}
 
-	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
-	setupDownloadFlags(flags)
-	flags.Set("uuid", "value")
+func TestDownloadConcurrencyFailure(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
 
-	err = runDownload(cfg, flags, []string{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Base(r.URL.Path) == "broken.txt" {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}
 
-	assert.Equal(t, "test error", err.Error())
+	ts := fakeSolutionServer(handler, "a.txt", "broken.txt", "c.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-concurrency")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("max-retries", "0")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "broken.txt", err.Error())
+	}
+}
+
+func TestDownloadReleasesConnectionsPerFile(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	files := []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"}
+
+	mux := http.NewServeMux()
+	ts := httptest.NewUnstartedServer(mux)
+	var mu sync.Mutex
+	var conns int
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns++
+			mu.Unlock()
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	})
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", files...))
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-connections")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("concurrency", "1")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	// A connection can only be reused once the previous response body is closed,
+	// so a single connection means each body was released before the next file.
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 1, conns)
+}
+
+func TestDownloadProgress(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "a.txt", "b.txt", "c.txt")
+	defer ts.Close()
+
+	testCases := []struct {
+		desc     string
+		quiet    bool
+		expected []string
+	}{
+		{
+			desc:     "reports each file",
+			quiet:    false,
+			expected: []string{"Downloaded 1/3 files", "Downloaded 2/3 files", "Downloaded 3/3 files"},
+		},
+		{
+			desc:     "quiet suppresses progress",
+			quiet:    true,
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "download-progress")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			out := &bytes.Buffer{}
+			Out = out
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			defer func(old bool) { quiet = old }(quiet)
+			quiet = tc.quiet
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			lines := []string{}
+			for _, line := range strings.Split(out.String(), "\n") {
+				if strings.HasPrefix(line, "Downloaded ") && strings.Contains(line, "/") {
+					lines = append(lines, line)
+				}
+			}
+			assert.Equal(t, tc.expected, lines)
+		})
+	}
+}
+
+func TestDownloadSummary(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	contents := map[string]string{
+		"/files/small.txt": "12345",
+		"/files/large.txt": strings.Repeat("x", 1500),
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, contents[r.URL.Path])
+	}
+	ts := fakeSolutionServer(handler, "small.txt", "large.txt")
+	defer ts.Close()
+
+	testCases := []struct {
+		desc    string
+		quiet   bool
+		summary bool
+	}{
+		{desc: "reports the size and time", quiet: false, summary: true},
+		{desc: "quiet suppresses the summary", quiet: true, summary: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "download-summary")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			out := &bytes.Buffer{}
+			Out = out
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			defer func(old bool) { quiet = old }(quiet)
+			quiet = tc.quiet
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			summary := regexp.MustCompile(`(?m)^Downloaded 2 files \(1\.5 KB\) in \d+(\.\d+)?m?s$`)
+			assert.Equal(t, tc.summary, summary.MatchString(out.String()), out.String())
+		})
+	}
+}
+
+func TestDownloadSummaryBytes(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat("x", len(r.URL.Path)))
+	}
+	ts := fakeSolutionServer(handler, "a.txt", "subdir/b.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-summary")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	summary, err := downloadSolution(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len("/files/a.txt")+len("/files/subdir/b.txt")), summary.Bytes)
+	assert.True(t, summary.Elapsed > 0)
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := []struct {
+		bytes    int64
+		expected string
+	}{
+		{bytes: 0, expected: "0 B"},
+		{bytes: 1023, expected: "1023 B"},
+		{bytes: 1024, expected: "1.0 KB"},
+		{bytes: 1536, expected: "1.5 KB"},
+		{bytes: 245 * 1024, expected: "245 KB"},
+		{bytes: 3 * 1024 * 1024, expected: "3.0 MB"},
+		{bytes: 5 * 1024 * 1024 * 1024 * 1024, expected: "5120 GB"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, formatBytes(tc.bytes))
+	}
+}
+
+func TestDownloadRetriesTransientFailures(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	oldDelay := api.DefaultRetryBaseDelay
+	defer func() { api.DefaultRetryBaseDelay = oldDelay }()
+	api.DefaultRetryBaseDelay = time.Millisecond
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	failTwice := func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[r.URL.Path]++
+		if attempts[r.URL.Path] <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	}
+
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		if failTwice(w, r) {
+			return
+		}
+		fmt.Fprint(w, "finally")
+	})
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		if failTwice(w, r) {
+			return
+		}
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-retry")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "finally", string(b))
+	assert.Equal(t, 3, attempts["/solutions/latest"])
+	assert.Equal(t, 3, attempts["/files/file.txt"])
+}
+
+func TestDownloadWithoutRetries(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		failed string
+		status int
+		err    string
+	}{
+		{
+			desc:   "failing solution request",
+			failed: "/solutions/latest",
+			status: http.StatusServiceUnavailable,
+		},
+		{
+			desc:   "failing file request",
+			failed: "/files/file.txt",
+			status: http.StatusServiceUnavailable,
+			err:    "unable to download 'file.txt': 503 Service Unavailable",
+		},
+		{
+			desc:   "rate limited file request",
+			failed: "/files/file.txt",
+			status: http.StatusTooManyRequests,
+			err:    "unable to download 'file.txt': 429 Too Many Requests",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			var mu sync.Mutex
+			attempts := map[string]int{}
+			fail := func(w http.ResponseWriter, r *http.Request) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts[r.URL.Path]++
+				if r.URL.Path == tc.failed {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tc.status)
+					return true
+				}
+				return false
+			}
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				if fail(w, r) {
+					return
+				}
+				fmt.Fprint(w, "content")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				if fail(w, r) {
+					return
+				}
+				json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-without-retries")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("strict", "true")
+			flags.Set("max-retries", "0")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if assert.Error(t, err) && tc.err != "" {
+				assert.Equal(t, tc.err, err.Error())
+			}
+			assert.Equal(t, 1, attempts[tc.failed])
+		})
+	}
+}
+
+func TestDownloadNegativeMaxRetries(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("max-retries", "-1")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig(os.TempDir(), "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Equal(t, "--max-retries must not be negative", err.Error())
+	}
+}
+
+func TestDownloadRateLimited(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	limitOnce := func(w http.ResponseWriter, r *http.Request) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[r.URL.Path]++
+		if attempts[r.URL.Path] == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return true
+		}
+		return false
+	}
+
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		if limitOnce(w, r) {
+			return
+		}
+		fmt.Fprint(w, "patience")
+	})
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		if limitOnce(w, r) {
+			return
+		}
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-rate-limited")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "patience", string(b))
+	assert.Equal(t, 2, attempts["/solutions/latest"])
+	assert.Equal(t, 2, attempts["/files/file.txt"])
+}
+
+func TestDownloadRateLimitedTooLong(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-rate-limited")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("max-retry-wait", "1s")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "rate limiting", err.Error())
+		assert.True(t, errors.Is(err, ErrRateLimited))
+	}
+}
+
+func TestDownloadUnauthorized(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error": {"type": "invalid_token", "message": "The token is invalid"}}`)
+	}))
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "The token is invalid", err.Error())
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+		assert.False(t, errors.Is(err, ErrNetwork))
+	}
+}
+
+func TestDownloadNotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"type": "exercise_not_found", "message": "The exercise does not exist"}}`)
+	}))
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "The exercise does not exist", err.Error())
+		assert.True(t, errors.Is(err, ErrNotFound))
+	}
+}
+
+func TestDownloadUnauthorizedWithoutMessage(t *testing.T) {
+	testCases := []struct {
+		desc string
+		body string
+	}{
+		{desc: "HTML error page", body: "<html><body><h1>401 Authorization Required</h1></body></html>"},
+		{desc: "empty body", body: ""},
+		{desc: "JSON without a message", body: `{"error": {}}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer ts.Close()
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+
+			err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+			if assert.Error(t, err) {
+				assert.Regexp(t, "didn't accept your token", err.Error())
+				assert.Contains(t, err.Error(), fmt.Sprintf("%s configure --token=YOUR_TOKEN", BinaryName))
+				assert.NotRegexp(t, "parse", err.Error())
+				assert.True(t, errors.Is(err, ErrUnauthorized))
+			}
+		})
+	}
+}
+
+func TestDownloadNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("max-retries", "0")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "connection refused", err.Error())
+		assert.True(t, errors.Is(err, ErrNetwork))
+	}
+}
+
+func TestDownloadUnavailableFiles(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch filepath.Base(r.URL.Path) {
+		case "missing.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "broken.txt":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "empty.txt":
+		default:
+			fmt.Fprint(w, "ok")
+		}
+	}
+	ts := fakeSolutionServer(handler, "ok.txt", "missing.txt", "broken.txt", "empty.txt")
+	defer ts.Close()
+
+	testCases := []struct {
+		desc   string
+		strict string
+	}{
+		{desc: "warns by default", strict: "false"},
+		{desc: "fails when strict", strict: "true"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "download-unavailable")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			errOut := &bytes.Buffer{}
+			Err = errOut
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("max-retries", "0")
+			flags.Set("concurrency", "1")
+			flags.Set("strict", tc.strict)
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+
+			if tc.strict == "true" {
+				if assert.Error(t, err) {
+					assert.Regexp(t, "unable to download 'missing.txt': 404 Not Found", err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Regexp(t, "Skipping 'missing.txt', the server responded with 404 Not Found", errOut.String())
+			assert.Regexp(t, "Skipping 'broken.txt', the server responded with 500 Internal Server Error", errOut.String())
+			assert.NotRegexp(t, "empty.txt", errOut.String())
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			_, err = os.Stat(filepath.Join(dir, "ok.txt"))
+			assert.NoError(t, err)
+			_, err = os.Stat(filepath.Join(dir, "empty.txt"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestDownloadDryRun(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var fileRequests int32
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fileRequests, 1)
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file-1.txt", "subdir/file-2.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-dry-run")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	Out = out
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("dry-run", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	expected := []string{
+		filepath.Join(dir, ".exercism", "metadata.json"),
+		filepath.Join(dir, "file-1.txt"),
+		filepath.Join(dir, "subdir", "file-2.txt"),
+		"",
+	}
+	assert.Equal(t, expected, strings.Split(out.String(), "\n"))
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fileRequests))
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err), "It should not create the exercise directory.")
+}
+
+func TestDownloadOutputDir(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-output-dir")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	workspaceDir := filepath.Join(tmpDir, "workspace")
+	reviewDir := filepath.Join(tmpDir, "review", "alice")
+
+	testCases := []struct {
+		desc, outputDir, expectedDir string
+	}{
+		{
+			desc:        "defaults to the workspace",
+			outputDir:   "",
+			expectedDir: filepath.Join(workspaceDir, "bogus-track", "bogus-exercise"),
+		},
+		{
+			desc:        "overridden by --output-dir",
+			outputDir:   reviewDir,
+			expectedDir: reviewDir,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("output-dir", tc.outputDir)
+
+			err = runDownload(context.Background(), fakeDownloadConfig(workspaceDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			_, err = os.Stat(filepath.Join(tc.expectedDir, "file.txt"))
+			assert.NoError(t, err)
+			metadata, err := workspace.NewExerciseMetadata(tc.expectedDir)
+			assert.NoError(t, err)
+			assert.Equal(t, "bogus-id", metadata.ID)
+		})
+	}
+}
+
+func TestDownloadExpandsPaths(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("HOME does not determine the home directory on Windows")
+	}
+
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-expand")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", tmpDir)
+	os.Setenv("EXERCISM_TEST_ROOT", tmpDir)
+	defer os.Unsetenv("EXERCISM_TEST_ROOT")
+
+	testCases := []struct {
+		desc, workspace, outputDir, expectedDir string
+	}{
+		{
+			desc:        "workspace with ~",
+			workspace:   "~/workspace",
+			expectedDir: filepath.Join(tmpDir, "workspace", "bogus-track", "bogus-exercise"),
+		},
+		{
+			desc:        "workspace with an environment variable",
+			workspace:   "$EXERCISM_TEST_ROOT/env-workspace",
+			expectedDir: filepath.Join(tmpDir, "env-workspace", "bogus-track", "bogus-exercise"),
+		},
+		{
+			desc:        "output dir with ~ and an environment variable",
+			workspace:   "~/workspace",
+			outputDir:   "~/${EXERCISM_TEST_ROOT}-review",
+			expectedDir: filepath.Join(tmpDir, tmpDir+"-review"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("output-dir", tc.outputDir)
+			flags.Set("force", "true")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tc.workspace, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			_, err = os.Stat(filepath.Join(tc.expectedDir, "file.txt"))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDownloadOutputDirNotADirectory(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "download-output-dir")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	file := filepath.Join(tmpDir, "file.txt")
+	err = ioutil.WriteFile(file, []byte("not a dir"), os.FileMode(0644))
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("output-dir", filepath.Join(file, "nested"))
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, "http://example.com"), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "is not a directory", err.Error())
+	}
+}
+
+func TestDownloadJSON(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Base(r.URL.Path) == "empty.txt" {
+			return
+		}
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file-1.txt", "subdir/file-2.txt", "empty.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-json")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	Out = out
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("json", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	var summary DownloadResult
+	err = json.Unmarshal(out.Bytes(), &summary)
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	assert.Equal(t, "bogus-id", summary.ID)
+	assert.Equal(t, "bogus-track", summary.Track)
+	assert.Equal(t, "bogus-exercise", summary.Exercise)
+	assert.Equal(t, dir, summary.Destination)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "file-1.txt"),
+		filepath.Join(dir, "subdir", "file-2.txt"),
+	}, summary.Files)
+}
+
+func TestDownloadJSONError(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	errOut := &bytes.Buffer{}
+	Err = errOut
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("json", "true")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/home/whatever", "http://example.com"), flags, []string{})
+	assert.Error(t, err)
+
+	var body map[string]string
+	err = json.Unmarshal(errOut.Bytes(), &body)
+	assert.NoError(t, err)
+	assert.Equal(t, "need an --exercise name or a solution --uuid", body["error"])
+}
+
+func TestDownloadTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	testCases := []struct {
+		desc   string
+		config int
+		flag   string
+	}{
+		{desc: "from config", config: 1},
+		{desc: "flag overrides config", config: 60, flag: "1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := fakeDownloadConfig("/home/whatever", ts.URL)
+			cfg.UserViperConfig.Set("httptimeout", tc.config)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			// Inherited from the root command.
+			flags.Int("timeout", 0, "")
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("max-retries", "0")
+			if tc.flag != "" {
+				flags.Set("timeout", tc.flag)
+			}
+
+			start := time.Now()
+			err := runDownload(context.Background(), cfg, flags, []string{})
+			if assert.Error(t, err) {
+				assert.Regexp(t, "timeout awaiting response headers", err.Error())
+			}
+			assert.True(t, time.Since(start) < 5*time.Second)
+		})
+	}
+}
+
+func TestDownloadTimeoutSlowBody(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	// The file takes longer than the timeout to arrive, but never stalls.
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "slow.txt"))
+	})
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 15; i++ {
+			fmt.Fprint(w, "chunk\n")
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-timeout-slow-body")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	// Inherited from the root command.
+	flags.Int("timeout", 0, "")
+	flags.Set("timeout", "1")
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("max-retries", "0")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "slow.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("chunk\n", 15), string(b))
+}
+
+// countingTransport counts the requests that pass through it.
+type countingTransport struct {
+	requests int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestDownloadReusesClient(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "a.txt", "b.txt", "c.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-client")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	defer func(old bool) { quiet = old }(quiet)
+	quiet = true
+
+	d, err := newDownload(context.Background(), flags, fakeDownloadConfig(tmpDir, ts.URL).UserViperConfig)
+	assert.NoError(t, err)
+	if assert.NotNil(t, d.client) {
+		transport := &countingTransport{}
+		d.client.Client.Transport = transport
+
+		written, err := d.writeSolutionFiles(context.Background(), tmpDir)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(written))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&transport.requests))
+	}
+}
+
+func TestWriteSolutionFilesReturnsWritten(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/files/empty.txt":
+		case "/files/missing.txt":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprint(w, "contents")
+		}
+	}
+	ts := fakeSolutionServer(handler, "a.txt", "empty.txt", "missing.txt", "subdir/b.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-written")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	// A relative directory still gives absolute paths.
+	cwd, err := os.Getwd()
+	assert.NoError(t, err)
+	defer os.Chdir(cwd)
+	err = os.Chdir(tmpDir)
+	assert.NoError(t, err)
+	tmpDir, err = os.Getwd()
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	d, err := newDownload(context.Background(), flags, fakeDownloadConfig(tmpDir, ts.URL).UserViperConfig)
+	assert.NoError(t, err)
+
+	written, err := d.writeSolutionFiles(context.Background(), "exercise")
+	assert.NoError(t, err)
+	dir := filepath.Join(tmpDir, "exercise")
+	assert.Equal(t, []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "subdir", "b.txt")}, written)
+
+	var created []string
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			created = append(created, path)
+		}
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, written, created)
+}
+
+func TestWriteSolutionFilesSymlinkedDir(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "subdir/file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-symlinked-dir")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "exercise")
+	err = os.MkdirAll(filepath.Join(dir, "inside"), os.FileMode(0755))
+	assert.NoError(t, err)
+	outside := filepath.Join(tmpDir, "outside")
+	err = os.Mkdir(outside, os.FileMode(0755))
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	d, err := newDownload(context.Background(), flags, fakeDownloadConfig(tmpDir, ts.URL).UserViperConfig)
+	assert.NoError(t, err)
+
+	// A link that stays within the exercise directory is followed.
+	err = os.Symlink(filepath.Join(dir, "inside"), filepath.Join(dir, "subdir"))
+	assert.NoError(t, err)
+	_, err = d.writeSolutionFiles(context.Background(), dir)
+	assert.NoError(t, err)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "inside", "file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "contents", string(b))
+
+	// One that leads out of it isn't.
+	err = os.Remove(filepath.Join(dir, "subdir"))
+	assert.NoError(t, err)
+	err = os.Symlink(outside, filepath.Join(dir, "subdir"))
+	assert.NoError(t, err)
+	_, err = d.writeSolutionFiles(context.Background(), dir)
+	if assert.Error(t, err) {
+		assert.Equal(t, fmt.Sprintf("refusing to follow the link '%s' outside of '%s'", filepath.Join(dir, "subdir"), dir), err.Error())
+	}
+	entries, err := ioutil.ReadDir(outside)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestWriteSolutionFilesDiskFull(t *testing.T) {
+	// Writing to /dev/full fails as if the disk were full.
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("/dev/full is needed to simulate a full disk")
+	}
+
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-disk-full")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	// The file is written to a part file first, which stands in for the full disk.
+	part := filepath.Join(tmpDir, "file.txt.part")
+	err = os.Symlink("/dev/full", part)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	d, err := newDownload(context.Background(), flags, fakeDownloadConfig(tmpDir, ts.URL).UserViperConfig)
+	assert.NoError(t, err)
+
+	_, err = d.writeSolutionFiles(context.Background(), tmpDir)
+	if assert.Error(t, err) {
+		assert.Regexp(t, "^ran out of disk space while writing '.*file.txt'", err.Error())
+		assert.True(t, errors.Is(err, syscall.ENOSPC))
+	}
+
+	_, err = os.Lstat(part)
+	assert.True(t, os.IsNotExist(err), "the partial file wasn't cleaned up")
+	_, err = os.Lstat(filepath.Join(tmpDir, "file.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadFileRequestError(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(fakePayload("http://example.com/", "file\x7f.txt"))
+	}))
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-request-error")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	assert.NotPanics(t, func() {
+		err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "unable to download 'file", err.Error())
+	}
+}
+
+func TestDownloadPersonal(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var query netURL.Values
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	})
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		payload := fakePayload(ts.URL+"/files/", "file.txt")
+		payload.Solution.Team.Slug = "bogus-team"
+		json.NewEncoder(w).Encode(payload)
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-personal")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("track", "bogus-track")
+	flags.Set("personal", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "bogus-exercise", query.Get("exercise_id"))
+	_, ok := query["team_id"]
+	assert.False(t, ok)
+
+	_, err = os.Stat(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(tmpDir, "teams"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadPersonalWithTeam(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("team", "bogus-team")
+	flags.Set("personal", "true")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/home/whatever", "http://example.com"), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "--personal and --team cannot be used together", err.Error())
+	}
+}
+
+func TestDownloadPayloadValidate(t *testing.T) {
+	testCases := []struct {
+		desc, baseURL string
+		files         []string
+		valid         bool
+	}{
+		{desc: "valid base URL", baseURL: "http://example.com/files/", files: []string{"a.txt"}, valid: true},
+		{desc: "empty base URL", baseURL: "", files: []string{"a.txt"}, valid: false},
+		{desc: "relative base URL", baseURL: "/files/", files: []string{"a.txt"}, valid: false},
+		{desc: "garbage base URL", baseURL: "::bogus", files: []string{"a.txt"}, valid: false},
+		{desc: "no files to download", baseURL: "", files: nil, valid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			payload := fakePayload(tc.baseURL, tc.files...)
+			err := payload.validate()
+			if tc.valid {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Regexp(t, "invalid file download base URL", err.Error())
+			}
+		})
+	}
+}
+
+func TestDownloadFromFile(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	tmpDir, err := ioutil.TempDir("", "download-from-file")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	payload := fakePayload("", "file-1.txt", "subdir/file-2.txt", "empty.txt")
+	payload.Solution.FileContents = map[string][]byte{
+		"file-1.txt":        []byte("this is file 1"),
+		"subdir/file-2.txt": []byte("this is file 2"),
+		"empty.txt":         []byte(""),
+	}
+	b, err := json.Marshal(payload)
+	assert.NoError(t, err)
+	fixture := filepath.Join(tmpDir, "payload.json")
+	err = ioutil.WriteFile(fixture, b, os.FileMode(0644))
+	assert.NoError(t, err)
+
+	// No token or API; nothing should need the network.
+	v := viper.New()
+	v.Set("workspace", tmpDir)
+	cfg := config.Config{
+		UserViperConfig: v,
+	}
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("from-file", fixture)
+
+	err = runDownload(context.Background(), cfg, flags, []string{})
+	assert.NoError(t, err)
+
+	assertDownloadedCorrectFiles(t, tmpDir)
+
+	metadata, err := workspace.NewExerciseMetadata(filepath.Join(tmpDir, "bogus-track", "bogus-exercise"))
+	assert.NoError(t, err)
+	assert.Equal(t, "bogus-id", metadata.ID)
+}
+
+func TestDownloadExecutableFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't have an executable bit")
+	}
+
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "bin/run.sh", "build.PY", "file.txt")
+	defer ts.Close()
+
+	testCases := []struct {
+		desc       string
+		exts       string
+		executable map[string]bool
+	}{
+		{
+			desc:       "default extensions",
+			executable: map[string]bool{"bin/run.sh": true, "build.PY": false, "file.txt": false},
+		},
+		{
+			desc:       "custom extensions",
+			exts:       "py,.sh",
+			executable: map[string]bool{"bin/run.sh": true, "build.PY": true, "file.txt": false},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir("", "download-executable")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			if tc.exts != "" {
+				flags.Set("executable-ext", tc.exts)
+			}
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			for file, executable := range tc.executable {
+				info, err := os.Stat(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", filepath.FromSlash(file)))
+				assert.NoError(t, err)
+				assert.Equal(t, executable, info.Mode()&0111 != 0, file)
+			}
+		})
+	}
+}
+
+func TestDownloadResume(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	errOut := &bytes.Buffer{}
+	Err = errOut
+
+	var mu sync.Mutex
+	requested := []string{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, strings.TrimPrefix(r.URL.Path, "/files/"))
+		mu.Unlock()
+		fmt.Fprint(w, "downloaded")
+	}
+	ts := fakeSolutionServer(handler, "a.txt", "subdir/b.txt", "c.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-resume")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	err = os.MkdirAll(filepath.Join(dir, "subdir"), os.FileMode(0755))
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, "subdir", "b.txt"), []byte("partial"), os.FileMode(0644))
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("concurrency", "1")
+	flags.Set("resume", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"a.txt", "c.txt"}, requested)
+	assert.Regexp(t, "Resuming download, 1 of 3 files already present", errOut.String())
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "subdir", "b.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "partial", string(b))
+}
+
+func TestDownloadThroughProxy(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	// The API host doesn't exist, so requests only succeed through the proxy.
+	const apibaseurl = "http://api.exercism.invalid"
+
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+
+		if r.URL.Path == "/solutions/latest" {
+			json.NewEncoder(w).Encode(fakePayload(apibaseurl+"/files/", "file.txt"))
+			return
+		}
+		fmt.Fprint(w, "proxied")
+	}))
+	defer proxy.Close()
+
+	testCases := []struct {
+		desc  string
+		flag  string
+		proxy string
+	}{
+		{desc: "flag", flag: proxy.URL},
+		{desc: "config", proxy: proxy.URL},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			proxied = nil
+
+			tmpDir, err := ioutil.TempDir("", "download-proxy")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			cfg := fakeDownloadConfig(tmpDir, apibaseurl)
+			cfg.UserViperConfig.Set("proxyurl", tc.proxy)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("proxy", tc.flag)
+
+			err = runDownload(context.Background(), cfg, flags, []string{})
+			assert.NoError(t, err)
+
+			b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, "proxied", string(b))
+			assert.Equal(t, []string{
+				apibaseurl + "/solutions/latest?exercise_id=bogus-exercise&source=flags",
+				apibaseurl + "/files/file.txt",
+			}, proxied)
+		})
+	}
+}
+
+func TestDownloadInvalidProxy(t *testing.T) {
+	testCases := []struct {
+		proxy    string
+		expected string
+	}{
+		{proxy: "http://[::1", expected: "not a valid URL"},
+		{proxy: "ftp://proxy.example.com", expected: "must use http, https, or socks5"},
+		{proxy: "socks5://", expected: "missing a host"},
+	}
+
+	for _, tc := range testCases {
+		flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+		setupDownloadFlags(flags)
+		flags.Set("exercise", "bogus-exercise")
+		flags.Set("proxy", tc.proxy)
+
+		err := runDownload(context.Background(), fakeDownloadConfig("/tmp", "http://example.com"), flags, []string{})
+		if assert.Error(t, err, tc.proxy) {
+			assert.Regexp(t, tc.expected, err.Error())
+		}
+	}
+}
+
+func TestDownloadVerbose(t *testing.T) {
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	oldVerbose := debug.Verbose
+	defer func() { debug.Verbose = oldVerbose }()
+	debug.Verbose = true
+
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "missing.txt") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "content")
+	}, "file.txt", "missing.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-verbose")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("track", "bogus-track")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	log := Err.(*bytes.Buffer).String()
+	assert.Contains(t, log, "GET "+ts.URL+"/solutions/latest?exercise_id=bogus-exercise&source=flags&track_id=bogus-track\n")
+	assert.Contains(t, log, "GET "+ts.URL+"/files/file.txt\n  Authorization: Bearer ******\n  200 OK\n")
+	assert.Contains(t, log, "GET "+ts.URL+"/files/missing.txt\n  Authorization: Bearer ******\n  404 Not Found\n")
+	assert.NotContains(t, log, "abc123")
+}
+
+func TestDownloadRedirects(t *testing.T) {
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	oldVerbose := debug.Verbose
+	defer func() { debug.Verbose = oldVerbose }()
+	debug.Verbose = true
+
+	// The mirror sends /files/file.txt to /mirror/file.txt by way of /hop/1 and /hop/2.
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	})
+	mux.HandleFunc("/files/file.txt", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hop/1", http.StatusFound)
+	})
+	mux.HandleFunc("/hop/1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hop/2", http.StatusFound)
+	})
+	mux.HandleFunc("/hop/2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/mirror/file.txt", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/mirror/file.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content")
+	})
+
+	testCases := []struct {
+		desc         string
+		maxRedirects string
+		err          string
+	}{
+		{desc: "within the limit", maxRedirects: "3"},
+		{
+			desc:         "past the limit",
+			maxRedirects: "2",
+			err:          "unable to download 'file.txt': it was redirected more than the --max-redirects of 2 times",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			Err.(*bytes.Buffer).Reset()
+
+			tmpDir, err := ioutil.TempDir("", "download-redirects")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("max-redirects", tc.maxRedirects)
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			path := filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt")
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.err, err.Error())
+				}
+				_, err = os.Stat(path)
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, Err.(*bytes.Buffer).String(), "GET "+ts.URL+"/files/file.txt\n  Authorization: Bearer ******\n  200 OK\n  Redirected to "+ts.URL+"/mirror/file.txt\n")
+			b, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "content", string(b))
+		})
+	}
+}
+
+func TestDownloadNegativeMaxRedirects(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("max-redirects", "-1")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig(os.TempDir(), "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Equal(t, "--max-redirects must not be negative", err.Error())
+	}
+}
+
+func TestDownloadSolutionHost(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		apibaseurl string
+		url        string
+		warning    string
+	}{
+		{
+			desc:       "same host",
+			apibaseurl: "http://example.com/api/v1",
+			url:        "http://example.com/solutions/bogus-id",
+		},
+		{
+			desc:       "API subdomain",
+			apibaseurl: "https://api.exercism.io/v1",
+			url:        "https://exercism.io/solutions/bogus-id",
+		},
+		{
+			desc:       "www",
+			apibaseurl: "https://exercism.io/api/v1",
+			url:        "https://www.exercism.io/solutions/bogus-id",
+		},
+		{
+			desc:       "self-hosted API subdomain",
+			apibaseurl: "https://api.example.org/v1",
+			url:        "https://example.org/solutions/bogus-id",
+		},
+		{
+			desc:       "API without a subdomain",
+			apibaseurl: "http://127.0.0.1:8080",
+			url:        "http://127.0.0.1:8080/solutions/bogus-id",
+		},
+		{
+			desc:       "different host",
+			apibaseurl: "https://exercism.io/api/v1",
+			url:        "https://exercism.example.com/solutions/bogus-id",
+			warning:    "WARNING: The solution is on exercism.example.com, but the configured API is that of exercism.io. Check the apibaseurl in the user config.",
+		},
+		{
+			desc:       "no solution URL",
+			apibaseurl: "https://exercism.io/api/v1",
+			url:        "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			d := download{apibaseurl: tc.apibaseurl, payload: &downloadPayload{}}
+			d.payload.Solution.URL = tc.url
+			d.checkSolutionHost()
+
+			if tc.warning == "" {
+				assert.Equal(t, "", Err.(*bytes.Buffer).String())
+				return
+			}
+			assert.Contains(t, Err.(*bytes.Buffer).String(), tc.warning)
+		})
+	}
+}
+
+func TestDownloadSolutionOnSameHost(t *testing.T) {
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content")
+	}, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-same-host")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+	assert.NotContains(t, Err.(*bytes.Buffer).String(), "WARNING")
+}
+
+func TestRedactToken(t *testing.T) {
+	errBase := errors.New("Bearer abc123 was rejected")
+	wrapped := fmt.Errorf("unable to download 'file.txt': %w", errBase)
+
+	err := redactToken(wrapped, "abc123")
+	assert.Equal(t, "unable to download 'file.txt': Bearer [REDACTED] was rejected", err.Error())
+	assert.True(t, errors.Is(err, errBase))
+
+	assert.Equal(t, wrapped, redactToken(wrapped, "xyz789"))
+	assert.Equal(t, wrapped, redactToken(wrapped, ""))
+	assert.Nil(t, redactToken(nil, "abc123"))
+}
+
+func TestDownloadRedactsTokenFromErrors(t *testing.T) {
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": {"type": "invalid", "message": "cannot use %s"}}`, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("json", "true")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "cannot use Bearer [REDACTED]", err.Error())
+	}
+	assert.NotContains(t, Err.(*bytes.Buffer).String(), "abc123")
+}
+
+func TestParseSolutionURL(t *testing.T) {
+	testCases := []struct {
+		url                     string
+		uuid, team, track, slug string
+	}{
+		{
+			url:  "https://exercism.io/my/solutions/abc-123",
+			uuid: "abc-123",
+		},
+		{
+			url:  "https://exercism.io/mentor/solutions/abc-123?iteration_idx=2",
+			uuid: "abc-123",
+		},
+		{
+			url:  "https://exercism.io/solutions/abc-123/",
+			uuid: "abc-123",
+		},
+		{
+			url:   "https://exercism.io/tracks/go/exercises/hello-world",
+			track: "go",
+			slug:  "hello-world",
+		},
+		{
+			url:   "https://exercism.io/teams/bogus-team/tracks/go/exercises/hello-world",
+			team:  "bogus-team",
+			track: "go",
+			slug:  "hello-world",
+		},
+	}
+
+	for _, tc := range testCases {
+		d := &download{}
+		err := d.parseSolutionURL(tc.url)
+		assert.NoError(t, err, tc.url)
+		assert.Equal(t, tc.uuid, d.uuid, tc.url)
+		assert.Equal(t, tc.team, d.team, tc.url)
+		assert.Equal(t, tc.track, d.track, tc.url)
+		assert.Equal(t, tc.slug, d.slug, tc.url)
+	}
+}
+
+func TestParseSolutionURLInvalid(t *testing.T) {
+	for _, url := range []string{
+		"https://exercism.io/my/tracks",
+		"https://exercism.io/tracks/go",
+		"http://[::1",
+	} {
+		err := (&download{}).parseSolutionURL(url)
+		assert.Error(t, err, url)
+	}
+}
+
+func TestDownloadByURL(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var requested string
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content")
+	})
+	mux.HandleFunc("/solutions/", func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-by-url")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("url", "https://exercism.io/my/solutions/bogus-id")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "/solutions/bogus-id", requested)
+}
+
+func TestDownloadByURLWithUUID(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("url", "https://exercism.io/my/solutions/bogus-id")
+	flags.Set("uuid", "bogus-id")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", "http://example.com"), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "--url cannot be used with --uuid or --exercise", err.Error())
+	}
+}
+
+func TestDownloadTrackAmbiguous(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"type": "track_ambiguous", "message": "Please specify a track", "possible_track_ids": ["go", "rust"]}}`)
+	}))
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "^Please specify a track", err.Error())
+		for _, track := range []string{"go", "rust"} {
+			example := fmt.Sprintf("\n    %s download --exercise=bogus-exercise --track=%s", BinaryName, track)
+			assert.Contains(t, err.Error(), example)
+		}
+		assert.True(t, errors.Is(err, ErrTrackAmbiguous))
+		assert.False(t, errors.Is(err, ErrTeamAmbiguous))
+		var ambiguous *ambiguousError
+		if assert.True(t, errors.As(err, &ambiguous)) {
+			assert.Equal(t, []string{"go", "rust"}, ambiguous.ids)
+		}
+	}
+}
+
+func TestDownloadDefaultTrack(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		flags    map[string]string
+		expected string
+	}{
+		{
+			desc:     "default track",
+			flags:    map[string]string{"exercise": "bogus-exercise"},
+			expected: "go",
+		},
+		{
+			desc:     "track flag",
+			flags:    map[string]string{"exercise": "bogus-exercise", "track": "rust"},
+			expected: "rust",
+		},
+		{
+			desc:     "uuid",
+			flags:    map[string]string{"uuid": "bogus-id"},
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			// Without a track, the exercise is ambiguous.
+			var track string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				track = r.URL.Query().Get("track_id")
+				if track == "" && r.URL.Query().Get("exercise_id") != "" {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"error": {"type": "track_ambiguous", "message": "Please specify a track", "possible_track_ids": ["go", "rust"]}}`)
+					return
+				}
+				json.NewEncoder(w).Encode(fakePayload("http://" + r.Host + "/files/"))
+			}))
+			defer ts.Close()
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+			usrCfg := fakeDownloadConfig("/tmp", ts.URL).UserViperConfig
+			usrCfg.Set("defaulttrack", " go ")
+
+			_, err := newDownload(context.Background(), flags, usrCfg)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, track)
+		})
+	}
+}
+
+func TestDownloadOtherUsersSolution(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		isRequester bool
+		quiet       bool
+		notice      bool
+	}{
+		{desc: "own solution", isRequester: true, notice: false},
+		{desc: "other user's solution", isRequester: false, notice: true},
+		{desc: "other user's solution quietly", isRequester: false, quiet: true, notice: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "content")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				payload := fakePayload(ts.URL+"/files/", "file.txt")
+				payload.Solution.User.IsRequester = tc.isRequester
+				json.NewEncoder(w).Encode(payload)
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-other-user")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			defer func(old bool) { quiet = old }(quiet)
+			quiet = tc.quiet
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			notice := fmt.Sprintf("Downloading alice's solution to %s (read-only)\n", filepath.Join(tmpDir, "users", "alice", "bogus-track", "bogus-exercise"))
+			if tc.notice {
+				assert.Contains(t, Out.(*bytes.Buffer).String(), notice)
+			} else {
+				assert.NotContains(t, Out.(*bytes.Buffer).String(), "solution to")
+			}
+		})
+	}
+}
+
+func TestDownloadWithCustomCA(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	mux := http.NewServeMux()
+	ts := httptest.NewTLSServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "trusted")
+	})
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	})
+
+	tmpDir, err := ioutil.TempDir("", "download-cacert")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	cacert := filepath.Join(tmpDir, "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}
+	err = ioutil.WriteFile(cacert, pem.EncodeToMemory(block), os.FileMode(0600))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc      string
+		flag, cfg string
+		trusted   bool
+	}{
+		{desc: "without the CA", trusted: false},
+		{desc: "with the CA flag", flag: cacert, trusted: true},
+		{desc: "with the CA config", cfg: cacert, trusted: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			cfg := fakeDownloadConfig(filepath.Join(tmpDir, "workspace"), ts.URL)
+			cfg.UserViperConfig.Set("cacert", tc.cfg)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("cacert", tc.flag)
+			flags.Set("force", "true")
+			flags.Set("max-retries", "0")
+
+			err := runDownload(context.Background(), cfg, flags, []string{})
+			if !tc.trusted {
+				if assert.Error(t, err) {
+					assert.Regexp(t, "certificate", err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+
+			b, err := ioutil.ReadFile(filepath.Join(tmpDir, "workspace", "bogus-track", "bogus-exercise", "file.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, "trusted", string(b))
+		})
+	}
+}
+
+func TestDownloadInsecure(t *testing.T) {
+	mux := http.NewServeMux()
+	ts := httptest.NewTLSServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "untrusted")
+	})
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	})
+
+	testCases := []struct {
+		desc   string
+		flag   bool
+		config bool
+	}{
+		{desc: "verifies certificates by default"},
+		{desc: "with --insecure", flag: true},
+		{desc: "with the insecure config", config: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+			defer func(old bool) { insecure = old }(insecure)
+			insecure = tc.flag
+
+			tmpDir, err := ioutil.TempDir("", "download-insecure")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			cfg := fakeDownloadConfig(tmpDir, ts.URL)
+			cfg.UserViperConfig.Set("insecure", tc.config)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("max-retries", "0")
+
+			err = runDownload(context.Background(), cfg, flags, []string{})
+			if !tc.flag && !tc.config {
+				if assert.Error(t, err) {
+					assert.Regexp(t, "certificate", err.Error())
+				}
+				assert.NotContains(t, Err.(*bytes.Buffer).String(), "TLS certificates are not being verified")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, Err.(*bytes.Buffer).String(), "WARNING: TLS certificates are not being verified.")
+
+			b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, "untrusted", string(b))
+		})
+	}
+}
+
+func TestDownloadInvalidTLSFiles(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "download-tls-files")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	notPEM := filepath.Join(tmpDir, "not.pem")
+	err = ioutil.WriteFile(notPEM, []byte("bogus"), os.FileMode(0600))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc     string
+		flags    map[string]string
+		expected string
+	}{
+		{
+			desc:     "missing CA file",
+			flags:    map[string]string{"cacert": filepath.Join(tmpDir, "missing.pem")},
+			expected: "--cacert: unable to read",
+		},
+		{
+			desc:     "CA file without certificates",
+			flags:    map[string]string{"cacert": notPEM},
+			expected: "--cacert: '.*' has no PEM encoded certificates",
+		},
+		{
+			desc:     "client cert without key",
+			flags:    map[string]string{"clientcert": notPEM},
+			expected: "--clientcert and --clientkey must be used together",
+		},
+		{
+			desc:     "invalid client cert",
+			flags:    map[string]string{"clientcert": notPEM, "clientkey": notPEM},
+			expected: "--clientcert: unable to load",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			err := runDownload(context.Background(), fakeDownloadConfig(tmpDir, "http://example.com"), flags, []string{})
+			if assert.Error(t, err) {
+				assert.Regexp(t, tc.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestDownloadPathTraversal(t *testing.T) {
+	testCases := []struct {
+		desc, file string
+		expected   string
+	}{
+		{desc: "parent directory", file: "../escape.txt"},
+		{desc: "nested parent directories", file: "nested/../../../escape.txt"},
+		{desc: "parent directories with backslashes", file: "..\\..\\escape.txt"},
+		{desc: "absolute path", file: "/etc/escape.txt", expected: filepath.Join("etc", "escape.txt")},
+		{desc: "dots in a filename", file: "..not-a-parent.txt", expected: "..not-a-parent.txt"},
+		{desc: "parent directory that stays inside", file: "a/../b/inside.txt", expected: filepath.Join("b", "inside.txt")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "content")
+			}, tc.file)
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-traversal")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(filepath.Join(tmpDir, "workspace"), ts.URL), flags, []string{})
+			if tc.expected == "" {
+				if assert.Error(t, err) {
+					assert.Regexp(t, "refusing to write .* outside of", err.Error())
+				}
+				_, err = os.Stat(filepath.Join(tmpDir, "workspace", "bogus-track", "escape.txt"))
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			assert.NoError(t, err)
+
+			_, err = os.Stat(filepath.Join(tmpDir, "workspace", "bogus-track", "bogus-exercise", tc.expected))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestIsWithinDir(t *testing.T) {
+	dir := filepath.Join("home", "alice", "exercise")
+
+	assert.True(t, isWithinDir(dir, filepath.Join(dir, "file.txt")))
+	assert.True(t, isWithinDir(dir, filepath.Join(dir, "..file.txt")))
+	assert.False(t, isWithinDir(dir, filepath.Join(dir, "..", "file.txt")))
+	assert.False(t, isWithinDir(dir, filepath.Join(dir, "..")))
+}
+
+func TestDownloadUserConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "download-config")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	writeConfig := func(path, token, workspace string) {
+		b, err := json.Marshal(map[string]string{"token": token, "workspace": workspace})
+		assert.NoError(t, err)
+		err = ioutil.WriteFile(path, b, os.FileMode(0600))
+		assert.NoError(t, err)
+	}
+	writeConfig(filepath.Join(tmpDir, "user.json"), "default-token", "/default/workspace")
+	alternate := filepath.Join(tmpDir, "ci.json")
+	writeConfig(alternate, "ci-token", "/ci/workspace")
+
+	testCases := []struct {
+		desc, config, token, workspace string
+	}{
+		{desc: "default location", token: "default-token", workspace: "/default/workspace"},
+		{desc: "--config", config: alternate, token: "ci-token", workspace: "/ci/workspace"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("config", tc.config)
+
+			v, err := downloadUserConfig(tmpDir, flags)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.token, v.GetString("token"))
+			assert.Equal(t, tc.workspace, v.GetString("workspace"))
+		})
+	}
+}
+
+func TestDownloadUserConfigMissingFile(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("config", filepath.Join(os.TempDir(), "no-such-exercism-config.json"))
+
+	_, err := downloadUserConfig(os.TempDir(), flags)
+	if assert.Error(t, err) {
+		assert.Regexp(t, "unable to read config file", err.Error())
+	}
+}
+
+func TestLoadUserConfig(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "load-config")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(map[string]interface{}{
+		"token":     "default-token",
+		"workspace": "/default/workspace",
+		"profiles": map[string]interface{}{
+			"ci": map[string]string{"workspace": "/ci/workspace"},
+		},
+	})
+	assert.NoError(t, err)
+	path := filepath.Join(tmpDir, "other.json")
+	err = ioutil.WriteFile(path, b, os.FileMode(0600))
+	assert.NoError(t, err)
+
+	// The commands that share the download's settings all read them alike.
+	setups := map[string]func(*pflag.FlagSet){
+		"download-track": setupDownloadTrackFlags,
+		"refresh":        setupRefreshFlags,
+		"info":           setupInfoFlags,
+		"list":           setupUserConfigFlags,
+	}
+	for name, setup := range setups {
+		flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+		setup(flags)
+		flags.Set("config", path)
+		flags.Set("profile", "ci")
+		if flags.Lookup("token") != nil {
+			flags.Set("token", " flag-token ")
+		}
+
+		v, err := loadUserConfig(os.TempDir(), flags)
+		assert.NoError(t, err, name)
+		assert.Equal(t, "/ci/workspace", v.GetString("workspace"), name)
+		if flags.Lookup("token") != nil {
+			assert.Equal(t, "flag-token", v.GetString("token"), name)
+		} else {
+			assert.Equal(t, "default-token", v.GetString("token"), name)
+		}
+	}
+}
+
+func TestDownloadUserConfigFromEnv(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var auth string
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, "content")
+	}, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-config-env")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	// There is no config file in the config dir.
+	configDir := filepath.Join(tmpDir, "config")
+	workspace := filepath.Join(tmpDir, "workspace")
+	for key, value := range map[string]string{
+		"EXERCISM_TOKEN":        "env-token",
+		"EXERCISM_WORKSPACE":    workspace,
+		"EXERCISM_API_BASE_URL": ts.URL,
+	} {
+		old, ok := os.LookupEnv(key)
+		defer func(key string) {
+			if ok {
+				os.Setenv(key, old)
+			} else {
+				os.Unsetenv(key)
+			}
+		}(key)
+		os.Setenv(key, value)
+	}
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	v, err := downloadUserConfig(configDir, flags)
+	assert.NoError(t, err)
+	assert.NoError(t, validateUserConfig(v))
+	assert.Equal(t, workspace, v.GetString("workspace"))
+	assert.Equal(t, ts.URL, v.GetString("apibaseurl"))
+
+	err = runDownload(context.Background(), config.Config{UserViperConfig: v}, flags, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer env-token", auth)
+
+	_, err = os.Stat(filepath.Join(workspace, "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+
+	// The environment takes precedence over a config file.
+	b, err := json.Marshal(map[string]string{"token": "file-token", "workspace": "/file/workspace"})
+	assert.NoError(t, err)
+	err = os.Mkdir(configDir, os.FileMode(0755))
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(configDir, "user.json"), b, os.FileMode(0600))
+	assert.NoError(t, err)
+
+	v, err = downloadUserConfig(configDir, flags)
+	assert.NoError(t, err)
+	assert.Equal(t, "env-token", v.GetString("token"))
+	assert.Equal(t, workspace, v.GetString("workspace"))
+}
+
+func TestDownloadWithAlternateConfig(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var auth string
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, "content")
+	}, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-alternate-config")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	path := filepath.Join(tmpDir, "ci.json")
+	b, err := json.Marshal(map[string]string{
+		"token":      "ci-token",
+		"workspace":  filepath.Join(tmpDir, "ci-workspace"),
+		"apibaseurl": ts.URL,
+	})
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(path, b, os.FileMode(0600))
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("config", path)
+
+	v, err := downloadUserConfig(tmpDir, flags)
+	assert.NoError(t, err)
+
+	err = runDownload(context.Background(), config.Config{UserViperConfig: v}, flags, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer ci-token", auth)
+
+	_, err = os.Stat(filepath.Join(tmpDir, "ci-workspace", "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+}
+
+func TestProfileUserConfig(t *testing.T) {
+	v := viper.New()
+	v.Set("token", "personal-token")
+	v.Set("workspace", "/home/alice/exercism")
+	v.Set("apibaseurl", "https://api.exercism.io/v1")
+	v.Set("profiles", map[string]interface{}{
+		"work": map[string]interface{}{
+			"token":     "work-token",
+			"workspace": "/home/alice/work/exercism",
+		},
+	})
+
+	testCases := []struct {
+		desc, profile, token, workspace string
+	}{
+		{
+			desc:      "default profile",
+			profile:   "",
+			token:     "personal-token",
+			workspace: "/home/alice/exercism",
+		},
+		{
+			desc:      "named profile",
+			profile:   "work",
+			token:     "work-token",
+			workspace: "/home/alice/work/exercism",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			usrCfg, err := profileUserConfig(v, tc.profile)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.token, usrCfg.GetString("token"))
+			assert.Equal(t, tc.workspace, usrCfg.GetString("workspace"))
+			// Settings missing from the profile fall back to the default.
+			assert.Equal(t, "https://api.exercism.io/v1", usrCfg.GetString("apibaseurl"))
+		})
+	}
+
+	_, err := profileUserConfig(v, "bogus")
+	if assert.Error(t, err) {
+		assert.Regexp(t, "no profile named 'bogus'", err.Error())
+	}
+}
+
+func TestDownloadWithProfile(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var auth string
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, "content")
+	}, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-profile")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	cfg := fakeDownloadConfig(filepath.Join(tmpDir, "personal"), ts.URL)
+	cfg.UserViperConfig.Set("profiles", map[string]interface{}{
+		"work": map[string]interface{}{
+			"token":     "work-token",
+			"workspace": filepath.Join(tmpDir, "work"),
+		},
+	})
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("profile", "work")
+
+	err = runDownload(context.Background(), cfg, flags, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer work-token", auth)
+
+	_, err = os.Stat(filepath.Join(tmpDir, "work", "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+}
+
+func TestDownloadMissingWorkspace(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "download-missing-workspace")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc        string
+		workspace   string
+		interactive bool
+		answer      string
+		expectedErr string
+		notice      bool
+	}{
+		{
+			desc:      "existing workspace",
+			workspace: tmpDir,
+		},
+		{
+			desc:      "missing workspace",
+			workspace: filepath.Join(tmpDir, "created"),
+			notice:    true,
+		},
+		{
+			desc:        "missing workspace and parent",
+			workspace:   filepath.Join(tmpDir, "typo", "workspace"),
+			expectedErr: "and neither does",
+		},
+		{
+			desc:        "missing workspace, confirmed",
+			workspace:   filepath.Join(tmpDir, "confirmed"),
+			interactive: true,
+			answer:      "y\n",
+		},
+		{
+			desc:        "missing workspace, declined",
+			workspace:   filepath.Join(tmpDir, "declined"),
+			interactive: true,
+			answer:      "n\n",
+			expectedErr: "does not exist",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			oldIn, oldIsTerminal := In, stdinIsTerminal
+			defer func() { In, stdinIsTerminal = oldIn, oldIsTerminal }()
+			In = strings.NewReader(tc.answer)
+			stdinIsTerminal = func() bool { return tc.interactive }
+
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "content")
+			}, "file.txt")
+			defer ts.Close()
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+
+			err := runDownload(context.Background(), fakeDownloadConfig(tc.workspace, ts.URL), flags, []string{})
+			if tc.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Regexp(t, tc.expectedErr, err.Error())
+				}
+				_, err = os.Stat(tc.workspace)
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			assert.NoError(t, err)
+
+			_, err = os.Stat(filepath.Join(tc.workspace, "bogus-track", "bogus-exercise", "file.txt"))
+			assert.NoError(t, err)
+			if tc.notice {
+				assert.Contains(t, Err.(*bytes.Buffer).String(), "Creating the workspace")
+			} else {
+				assert.NotContains(t, Err.(*bytes.Buffer).String(), "Creating the workspace")
+			}
+		})
+	}
+}
+
+func TestDownloadMissingWorkspacePrintDestination(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	oldIn, oldIsTerminal := In, stdinIsTerminal
+	defer func() { In, stdinIsTerminal = oldIn, oldIsTerminal }()
+	In = strings.NewReader("y\n")
+	stdinIsTerminal = func() bool { return true }
+
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content")
+	}, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-missing-workspace")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+	workspace := filepath.Join(tmpDir, "workspace")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("print-destination", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(workspace, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	// The question is asked on Err, leaving Out to the destination alone.
+	assert.Contains(t, Err.(*bytes.Buffer).String(), "Create it?")
+	assert.Equal(t, filepath.Join(workspace, "bogus-track", "bogus-exercise")+"\n", Out.(*bytes.Buffer).String())
+}
+
+func TestDownloadSkipUnchanged(t *testing.T) {
+	md5Sum := func(s string) string {
+		return fmt.Sprintf(`"%x"`, md5.Sum([]byte(s)))
+	}
+	sha256Sum := func(s string) string {
+		return fmt.Sprintf(`W/"%x"`, sha256.Sum256([]byte(s)))
+	}
+
+	testCases := []struct {
+		desc          string
+		etag          string
+		skipUnchanged bool
+		expected      string
+	}{
+		{desc: "matching MD5 ETag", etag: md5Sum("local"), skipUnchanged: true, expected: "local"},
+		{desc: "matching SHA-256 ETag", etag: sha256Sum("local"), skipUnchanged: true, expected: "local"},
+		{desc: "different ETag", etag: md5Sum("changed"), skipUnchanged: true, expected: "remote"},
+		{desc: "opaque ETag", etag: `"v2"`, skipUnchanged: true, expected: "remote"},
+		{desc: "without --skip-unchanged", etag: md5Sum("local"), skipUnchanged: false, expected: "remote"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", tc.etag)
+				fmt.Fprint(w, "remote")
+			}, "file.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-skip-unchanged")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			err = os.MkdirAll(dir, os.FileMode(0755))
+			assert.NoError(t, err)
+			err = ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("local"), os.FileMode(0644))
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("force", "true")
+			flags.Set("skip-unchanged", strconv.FormatBool(tc.skipUnchanged))
+
+			summary, err := downloadSolution(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{filepath.Join(dir, "file.txt")}, summary.Files)
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, "file.txt"))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(b))
+		})
+	}
+}
+
+func TestDownloadListFilesOnly(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		asJSON   bool
+		expected string
+	}{
+		{
+			desc:     "plain",
+			expected: fmt.Sprintf("file.txt\n%s\n", filepath.Join("subdir", "nested.txt")),
+		},
+		{
+			desc:     "JSON",
+			asJSON:   true,
+			expected: fmt.Sprintf("[\"file.txt\",%q]\n", filepath.Join("subdir", "nested.txt")),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			var requested int32
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requested, 1)
+			}, "file.txt", "subdir/nested.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-list-files")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("list-files-only", "true")
+			flags.Set("json", strconv.FormatBool(tc.asJSON))
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, Out.(*bytes.Buffer).String())
+			assert.Equal(t, int32(0), atomic.LoadInt32(&requested))
+
+			entries, err := ioutil.ReadDir(tmpDir)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestDownloadTeamAmbiguous(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error": {"type": "team_ambiguous", "message": "Please specify a team", "possible_team_ids": ["red", "blue"]}}`)
+	}))
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("track", "bogus-track")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "^Please specify a team", err.Error())
+		assert.Contains(t, err.Error(), "one of the teams:")
+		for _, team := range []string{"red", "blue"} {
+			example := fmt.Sprintf("\n    %s download --exercise=bogus-exercise --track=bogus-track --team=%s", BinaryName, team)
+			assert.Contains(t, err.Error(), example)
+		}
+		assert.True(t, errors.Is(err, ErrTeamAmbiguous))
+		assert.False(t, errors.Is(err, ErrTrackAmbiguous))
+	}
+}
+
+func TestAmbiguousErrorWithoutSlug(t *testing.T) {
+	err := &ambiguousError{message: "Please specify a team", flag: "team", ids: []string{"red", "blue"}}
+	assert.Equal(t, "Please specify a team:\n\n    red\n    blue", err.Error())
+}
+
+func TestWarningColors(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		terminal bool
+		noColor  bool
+		envVar   string
+		colored  bool
+	}{
+		{desc: "terminal", terminal: true, colored: true},
+		{desc: "not a terminal", terminal: false, colored: false},
+		{desc: "--no-color", terminal: true, noColor: true, colored: false},
+		{desc: "NO_COLOR", terminal: true, envVar: "1", colored: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			oldIsTerminal, oldNoColor := isTerminal, noColor
+			defer func() { isTerminal, noColor = oldIsTerminal, oldNoColor }()
+			isTerminal = func(io.Writer) bool { return tc.terminal }
+			noColor = tc.noColor
+
+			oldEnv, hadEnv := os.LookupEnv("NO_COLOR")
+			defer func() {
+				if hadEnv {
+					os.Setenv("NO_COLOR", oldEnv)
+				} else {
+					os.Unsetenv("NO_COLOR")
+				}
+			}()
+			if tc.envVar != "" {
+				os.Setenv("NO_COLOR", tc.envVar)
+			} else {
+				os.Unsetenv("NO_COLOR")
+			}
+
+			warnf("Skipping '%s'.", "file.txt")
+
+			expected := "\nWARNING: Skipping 'file.txt'.\n"
+			if tc.colored {
+				expected = "\n\x1b[33mWARNING: Skipping 'file.txt'.\x1b[0m\n"
+			}
+			assert.Equal(t, expected, Err.(*bytes.Buffer).String())
+		})
+	}
+}
+
+func TestDownloadContinuesPartialFiles(t *testing.T) {
+	const contents = "0123456789abcdefghij"
+
+	testCases := []struct {
+		desc          string
+		part          string
+		validator     string
+		supportsRange bool
+		expectedRange string
+	}{
+		{
+			desc:          "server supports ranges",
+			part:          contents[:10],
+			validator:     `"v1"`,
+			supportsRange: true,
+			expectedRange: "bytes=10-",
+		},
+		{
+			desc:          "server ignores ranges",
+			part:          "stale",
+			validator:     `"v1"`,
+			supportsRange: false,
+			expectedRange: "bytes=5-",
+		},
+		{
+			desc:          "file changed since",
+			part:          "stale",
+			validator:     `"v0"`,
+			supportsRange: true,
+			expectedRange: "bytes=5-",
+		},
+		{
+			desc:          "partial file of an unknown version",
+			part:          contents[:10],
+			supportsRange: true,
+			expectedRange: "",
+		},
+		{
+			desc:          "no partial file",
+			supportsRange: true,
+			expectedRange: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			var requestedRange string
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				requestedRange = r.Header.Get("Range")
+				if !tc.supportsRange {
+					r.Header.Del("Range")
+				}
+				w.Header().Set("ETag", `"v1"`)
+				http.ServeContent(w, r, "asset.bin", time.Time{}, strings.NewReader(contents))
+			}, "asset.bin")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-partial")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			target := filepath.Join(dir, "asset.bin")
+			if tc.part != "" {
+				err = os.MkdirAll(dir, os.FileMode(0755))
+				assert.NoError(t, err)
+				err = ioutil.WriteFile(target+".part", []byte(tc.part), os.FileMode(0644))
+				assert.NoError(t, err)
+			}
+			if tc.validator != "" {
+				err = ioutil.WriteFile(target+".part.validator", []byte(tc.validator), os.FileMode(0644))
+				assert.NoError(t, err)
+			}
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("resume", "true")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedRange, requestedRange)
+
+			b, err := ioutil.ReadFile(target)
+			assert.NoError(t, err)
+			assert.Equal(t, contents, string(b))
+
+			for _, path := range []string{target + ".part", target + ".part.validator"} {
+				_, err = os.Stat(path)
+				assert.True(t, os.IsNotExist(err), path)
+			}
+		})
+	}
+}
+
+func TestDownloadPartialFileChangedBetweenAttempts(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	// The first attempt is cut off halfway, and the file changes before the next.
+	contents, etag := "0123456789abcdefghij", `"v1"`
+	var requests []string
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Header.Get("Range")+" "+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", etag)
+		if len(requests) == 1 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(contents)))
+			fmt.Fprint(w, contents[:10])
+			return
+		}
+		http.ServeContent(w, r, "asset.bin", time.Time{}, strings.NewReader(contents))
+	}, "asset.bin")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-partial-changed")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+	target := filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "asset.bin")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("strict", "true")
+	flags.Set("max-retries", "0")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.Error(t, err)
+	b, err := ioutil.ReadFile(target + ".part")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(b))
+	b, err = ioutil.ReadFile(target + ".part.validator")
+	assert.NoError(t, err)
+	assert.Equal(t, `"v1"`, string(b))
+
+	contents, etag = "ABCDEFGHIJKLMNOPQRST", `"v2"`
+	flags.Set("resume", "true")
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	// The server sent the whole of the new version, rather than its tail.
+	assert.Equal(t, []string{" ", `bytes=10- "v1"`}, requests)
+	b, err = ioutil.ReadFile(target)
+	assert.NoError(t, err)
+	assert.Equal(t, "ABCDEFGHIJKLMNOPQRST", string(b))
+}
+
+func TestDownloadWithInstructions(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		instructionsURL string
+		files           []string
+		expected        string
+	}{
+		{
+			desc:            "writes the instructions",
+			instructionsURL: "/instructions",
+			files:           []string{"file.txt"},
+			expected:        "# Bogus Exercise",
+		},
+		{
+			desc:            "without an instructions URL",
+			instructionsURL: "",
+			files:           []string{"file.txt"},
+		},
+		{
+			desc:            "keeps the solution's README",
+			instructionsURL: "/instructions",
+			files:           []string{"file.txt", "README.md"},
+			expected:        "content",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "content")
+			})
+			mux.HandleFunc("/instructions", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "# Bogus Exercise")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				payload := fakePayload(ts.URL+"/files/", tc.files...)
+				if tc.instructionsURL != "" {
+					payload.Solution.Exercise.InstructionsURL = ts.URL + tc.instructionsURL
+				}
+				json.NewEncoder(w).Encode(payload)
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-instructions")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("with-instructions", "true")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "README.md"))
+			if tc.expected == "" {
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(b))
+		})
+	}
+}
+
+func TestDownloadWriteURL(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		writeURL bool
+		url      string
+		expected string
+	}{
+		{
+			desc:     "writes the URL",
+			writeURL: true,
+			url:      "http://example.com/solutions/bogus-id",
+			expected: "http://example.com/solutions/bogus-id\n",
+		},
+		{
+			desc:     "without a URL",
+			writeURL: true,
+			url:      "",
+		},
+		{
+			desc:     "without --write-url",
+			writeURL: false,
+			url:      "http://example.com/solutions/bogus-id",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "content")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				payload := fakePayload(ts.URL+"/files/", "file.txt")
+				payload.Solution.URL = tc.url
+				json.NewEncoder(w).Encode(payload)
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-write-url")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("write-url", strconv.FormatBool(tc.writeURL))
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "SOLUTION_URL.txt"))
+			if tc.expected == "" {
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(b))
+		})
+	}
+}
+
+func TestDownloadCanceledDuringPayloadRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err := runDownload(ctx, fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.False(t, errors.Is(err, ErrNetwork))
+	}
+}
+
+func TestDownloadCanceledDuringFiles(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var requested []string
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+
+		if r.URL.Path == "/files/file-2.txt" {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, "content")
+	}, "file-1.txt", "file-2.txt", "file-3.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-canceled")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("concurrency", "1")
+
+	err = runDownload(ctx, fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, context.Canceled))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/files/file-1.txt", "/files/file-2.txt"}, requested)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	_, err = os.Stat(filepath.Join(dir, "file-3.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadCanceledRemovesCreatedFiles(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		exists bool
+	}{
+		{desc: "new destination", exists: false},
+		{desc: "existing destination", exists: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/files/sub/file-2.txt" {
+					// Leave a partly written file behind when canceling.
+					fmt.Fprint(w, "partial")
+					w.(http.Flusher).Flush()
+					time.Sleep(50 * time.Millisecond)
+					cancel()
+					<-r.Context().Done()
+					return
+				}
+				fmt.Fprint(w, "content")
+			}, "file-1.txt", "sub/file-2.txt", "file-3.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-canceled")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			if tc.exists {
+				err = os.MkdirAll(dir, os.FileMode(0755))
+				assert.NoError(t, err)
+				err = ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("mine"), os.FileMode(0644))
+				assert.NoError(t, err)
+			}
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("concurrency", "1")
+			flags.Set("force", "true")
+
+			err = runDownload(ctx, fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if assert.Error(t, err) {
+				assert.True(t, errors.Is(err, context.Canceled))
+			}
+
+			if !tc.exists {
+				_, err = os.Stat(filepath.Join(tmpDir, "bogus-track"))
+				assert.True(t, os.IsNotExist(err), "the new directories weren't removed")
+				return
+			}
+
+			var left []string
+			filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+				if err == nil && path != dir {
+					rel, _ := filepath.Rel(dir, path)
+					left = append(left, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			assert.Equal(t, []string{"notes.txt"}, left)
+		})
+	}
+}
+
+func TestDownloadSolutionsPath(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		path     string
+		uuid     string
+		expected string
+	}{
+		{desc: "default", path: "", expected: "/solutions/latest"},
+		{desc: "default with a uuid", path: "", uuid: "bogus-id", expected: "/solutions/bogus-id"},
+		{desc: "custom path", path: "/gateway/v1/solutions", uuid: "bogus-id", expected: "/gateway/v1/solutions/bogus-id"},
+		{desc: "custom path with a trailing slash", path: "/gateway/solutions/", expected: "/gateway/solutions/latest"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var requested string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = r.URL.Path
+				json.NewEncoder(w).Encode(fakePayload("http://" + r.Host + "/files/"))
+			}))
+			defer ts.Close()
+
+			cfg := fakeDownloadConfig("/tmp", ts.URL)
+			cfg.UserViperConfig.Set("apisolutionspath", tc.path)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			if tc.uuid == "" {
+				flags.Set("exercise", "bogus-exercise")
+			} else {
+				flags.Set("uuid", tc.uuid)
+			}
+			flags.Set("list-files-only", "true")
+
+			_, err := newDownload(context.Background(), flags, cfg.UserViperConfig)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, requested)
+		})
+	}
+}
+
+func TestDownloadTrimsUserConfig(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		apibaseurl string
+		workspace  string
+		token      string
+	}{
+		{desc: "padded", apibaseurl: " %s\n", workspace: "  %s\n", token: "abc123\n"},
+		{desc: "trailing slash", apibaseurl: "%s/", workspace: "%s", token: "abc123"},
+		{desc: "padded with a trailing slash", apibaseurl: "%s/ \n", workspace: "%s ", token: " abc123"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var requested, auth string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = r.URL.Path
+				auth = r.Header.Get("Authorization")
+				json.NewEncoder(w).Encode(fakePayload("http://" + r.Host + "/files/"))
+			}))
+			defer ts.Close()
+
+			cfg := fakeDownloadConfig("/tmp", ts.URL)
+			cfg.UserViperConfig.Set("apibaseurl", fmt.Sprintf(tc.apibaseurl, ts.URL))
+			cfg.UserViperConfig.Set("workspace", fmt.Sprintf(tc.workspace, "/tmp"))
+			cfg.UserViperConfig.Set("token", tc.token)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("list-files-only", "true")
+
+			d, err := newDownload(context.Background(), flags, cfg.UserViperConfig)
+			assert.NoError(t, err)
+			assert.Equal(t, "/solutions/latest", requested)
+			assert.Equal(t, "Bearer abc123", auth)
+			assert.Equal(t, ts.URL, d.apibaseurl)
+			assert.Equal(t, ts.URL+"/solutions/latest", d.url())
+			assert.Equal(t, "/tmp", d.workspace)
+		})
+	}
+}
+
+func TestDownloadInvalidSolutionsPath(t *testing.T) {
+	cfg := fakeDownloadConfig("/tmp", "http://example.com")
+	cfg.UserViperConfig.Set("apisolutionspath", "solutions")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	_, err := newDownload(context.Background(), flags, cfg.UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Regexp(t, "apisolutionspath 'solutions' .* must begin with a slash", err.Error())
+	}
+}
+
+func TestDownloadMaxFileSize(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		contents string
+		chunked  bool
+		err      bool
+	}{
+		{desc: "under the limit", contents: "1234567890", err: false},
+		{desc: "under the limit without a length", contents: "1234567890", chunked: true, err: false},
+		{desc: "over the limit by header", contents: "12345678901", err: true},
+		{desc: "over the limit by stream", contents: "12345678901", chunked: true, err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if tc.chunked {
+					// Flushing before the body leaves out the Content-Length.
+					w.(http.Flusher).Flush()
+				}
+				fmt.Fprint(w, tc.contents)
+			}
+			ts := fakeSolutionServer(handler, "file.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-max-file-size")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("max-file-size", "10")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+
+			target := filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt")
+			if !tc.err {
+				assert.NoError(t, err)
+				b, err := ioutil.ReadFile(target)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.contents, string(b))
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Equal(t, "refusing to download 'file.txt', it is larger than the --max-file-size of 10 bytes", err.Error())
+			}
+			for _, path := range []string{target, target + ".part"} {
+				_, err = os.Stat(path)
+				assert.True(t, os.IsNotExist(err), path)
+			}
+		})
+	}
+}
+
+func TestDownloadNegativeMaxFileSize(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("max-file-size", "-1")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig("/tmp", "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Equal(t, "--max-file-size must not be negative", err.Error())
+	}
+}
+
+func TestDownloadKeepEmpty(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		keepEmpty string
+		written   bool
+	}{
+		{desc: "skips empty files by default", keepEmpty: "false", written: false},
+		{desc: "writes empty files with --keep-empty", keepEmpty: "true", written: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if filepath.Base(r.URL.Path) == "file.txt" {
+					fmt.Fprint(w, "contents")
+				}
+			}
+			ts := fakeSolutionServer(handler, "file.txt", "pkg/__init__.py")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-keep-empty")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("keep-empty", tc.keepEmpty)
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			_, err = os.Stat(filepath.Join(dir, "file.txt"))
+			assert.NoError(t, err)
+
+			info, err := os.Stat(filepath.Join(dir, "pkg", "__init__.py"))
+			if tc.written {
+				assert.NoError(t, err)
+				assert.Equal(t, int64(0), info.Size())
+			} else {
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func TestDownloadOutputFormat(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		format       string
+		noNested     bool
+		team         string
+		handle       string
+		isRequester  bool
+		expectedPath []string
+	}{
+		{desc: "personal nested", format: "nested", handle: "alice", isRequester: true, expectedPath: []string{"bogus-track", "bogus-exercise"}},
+		{desc: "personal flat", format: "flat", handle: "alice", isRequester: true, expectedPath: []string{"bogus-track", "bogus-exercise"}},
+		{desc: "team nested", format: "nested", team: "red", handle: "alice", isRequester: true, expectedPath: []string{"teams", "red", "bogus-track", "bogus-exercise"}},
+		{desc: "team flat", format: "flat", team: "red", handle: "alice", isRequester: true, expectedPath: []string{"bogus-track", "bogus-exercise"}},
+		{desc: "other user nested", format: "nested", handle: "bob", isRequester: false, expectedPath: []string{"users", "bob", "bogus-track", "bogus-exercise"}},
+		{desc: "other user flat", format: "flat", handle: "bob", isRequester: false, expectedPath: []string{"bogus-track", "bogus-exercise"}},
+		{desc: "team not nested", noNested: true, team: "red", handle: "alice", isRequester: true, expectedPath: []string{"bogus-track", "bogus-exercise"}},
+		{desc: "other user not nested", noNested: true, handle: "bob", isRequester: false, expectedPath: []string{"bogus-track", "bogus-exercise"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "contents")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				payload := fakePayload(ts.URL+"/files/", "file.txt")
+				payload.Solution.Team.Slug = tc.team
+				payload.Solution.User.Handle = tc.handle
+				payload.Solution.User.IsRequester = tc.isRequester
+				json.NewEncoder(w).Encode(payload)
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-output-format")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			if tc.format != "" {
+				flags.Set("output-format", tc.format)
+			}
+			flags.Set("no-nested", strconv.FormatBool(tc.noNested))
+
+			summary, err := downloadSolution(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(append([]string{tmpDir}, tc.expectedPath...)...)
+			assert.Equal(t, dir, summary.Destination)
+			_, err = os.Stat(filepath.Join(dir, "file.txt"))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDownloadNesting(t *testing.T) {
+	testCases := []struct {
+		nestTeams, nestUsers bool
+		expectedPath         []string
+	}{
+		{nestTeams: true, nestUsers: true, expectedPath: []string{"teams", "red", "bogus-track", "bogus-exercise"}},
+		{nestTeams: true, nestUsers: false, expectedPath: []string{"teams", "red", "bogus-track", "bogus-exercise"}},
+		{nestTeams: false, nestUsers: true, expectedPath: []string{"users", "bob", "bogus-track", "bogus-exercise"}},
+		{nestTeams: false, nestUsers: false, expectedPath: []string{"bogus-track", "bogus-exercise"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("teams %t users %t", tc.nestTeams, tc.nestUsers), func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			// Bob's solution to an exercise of the red team.
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "contents")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				payload := fakePayload(ts.URL+"/files/", "file.txt")
+				payload.Solution.Team.Slug = "red"
+				payload.Solution.User.Handle = "bob"
+				payload.Solution.User.IsRequester = false
+				json.NewEncoder(w).Encode(payload)
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-nesting")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("nest-teams", strconv.FormatBool(tc.nestTeams))
+			flags.Set("nest-users", strconv.FormatBool(tc.nestUsers))
+
+			summary, err := downloadSolution(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(append([]string{tmpDir}, tc.expectedPath...)...)
+			assert.Equal(t, dir, summary.Destination)
+			_, err = os.Stat(filepath.Join(dir, "file.txt"))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDownloadNoNestedWithNestedOutputFormat(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("output-format", "nested")
+	flags.Set("no-nested", "true")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig(os.TempDir(), "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Equal(t, "--no-nested can't be used with --output-format=nested", err.Error())
+	}
+}
+
+func TestDownloadPathTemplate(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		template     string
+		team         string
+		format       string
+		expectedPath []string
+	}{
+		{desc: "default", template: "", expectedPath: []string{"bogus-track", "bogus-exercise"}},
+		{desc: "language first", template: "{language}/{track}/{slug}", expectedPath: []string{"Bogus Language", "bogus-track", "bogus-exercise"}},
+		{desc: "handle and slug", template: "{handle}/{slug}", expectedPath: []string{"alice", "bogus-exercise"}},
+		{desc: "literal text", template: "exercism-{track}/{slug}", expectedPath: []string{"exercism-bogus-track", "bogus-exercise"}},
+		{desc: "empty placeholder", template: "{team}/{track}/{slug}", expectedPath: []string{"bogus-track", "bogus-exercise"}},
+		{desc: "below the team directory", template: "{slug}", team: "red", expectedPath: []string{"teams", "red", "bogus-exercise"}},
+		{desc: "team placeholder when flat", template: "{team}/{slug}", team: "red", format: "flat", expectedPath: []string{"red", "bogus-exercise"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "contents")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				payload := fakePayload(ts.URL+"/files/", "file.txt")
+				payload.Solution.Exercise.Track.Language = "Bogus Language"
+				payload.Solution.Team.Slug = tc.team
+				json.NewEncoder(w).Encode(payload)
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-path-template")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			cfg := fakeDownloadConfig(tmpDir, ts.URL)
+			cfg.UserViperConfig.Set("exercisepathtemplate", tc.template)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			if tc.format != "" {
+				flags.Set("output-format", tc.format)
+			}
+
+			summary, err := downloadSolution(context.Background(), cfg, flags)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(append([]string{tmpDir}, tc.expectedPath...)...)
+			assert.Equal(t, dir, summary.Destination)
+			_, err = os.Stat(filepath.Join(dir, "file.txt"))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDownloadInvalidPathTemplate(t *testing.T) {
+	testCases := []struct {
+		template string
+		err      string
+	}{
+		{template: "{track}/{exercise}", err: "unknown placeholder '{exercise}'"},
+		{template: "{Track}/{slug}", err: "unknown placeholder '{Track}'"},
+		{template: "/exercises/{slug}", err: "must be relative to the workspace"},
+		{template: "../{track}/{slug}", err: "must not leave the workspace"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.template, func(t *testing.T) {
+			cfg := fakeDownloadConfig("/tmp", "http://example.com")
+			cfg.UserViperConfig.Set("exercisepathtemplate", tc.template)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+
+			_, err := newDownload(context.Background(), flags, cfg.UserViperConfig)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+		})
+	}
+}
+
+func TestDownloadInvalidOutputFormat(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("output-format", "tree")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig("/tmp", "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Equal(t, "--output-format must be 'nested' or 'flat', not 'tree'", err.Error())
+	}
+}
+
+func TestDownloadWithTokenFlag(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		configToken string
+		flagToken   string
+		expected    string
+	}{
+		{desc: "flag token wins over the config", configToken: "abc123", flagToken: "flag-token", expected: "Bearer flag-token"},
+		{desc: "empty flag falls back to the config", configToken: "abc123", flagToken: "", expected: "Bearer abc123"},
+		{desc: "flag token without one in the config", configToken: "", flagToken: "flag-token", expected: "Bearer flag-token"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			var mu sync.Mutex
+			auths := map[string]bool{}
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				auths[r.Header.Get("Authorization")] = true
+				mu.Unlock()
+				fmt.Fprint(w, "contents")
+			}
+			ts := fakeSolutionServer(handler, "file.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-token-flag")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			cfg := fakeDownloadConfig(tmpDir, ts.URL)
+			cfg.UserViperConfig.Set("token", tc.configToken)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("token", tc.flagToken)
+
+			err = runDownload(context.Background(), cfg, flags, []string{})
+			assert.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, map[string]bool{tc.expected: true}, auths)
+			assert.Equal(t, tc.configToken, cfg.UserViperConfig.GetString("token"))
+		})
+	}
+}
+
+func TestDownloadRedactsTokenFlagFromErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": {"type": "invalid", "message": "cannot use %s"}}`, r.Header.Get("Authorization"))
+	}))
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("token", "flag-token")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/tmp", ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "cannot use Bearer [REDACTED]", err.Error())
+	}
+}
+
+func TestDownloadUserAgent(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		config   string
+		flag     string
+		expected string
+	}{
+		{desc: "default", expected: api.UserAgent},
+		{desc: "from the config", config: "ConfigAgent/1.0", expected: "ConfigAgent/1.0"},
+		{desc: "flag wins over the config", config: "ConfigAgent/1.0", flag: "FlagAgent/2.0", expected: "FlagAgent/2.0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			var mu sync.Mutex
+			agents := map[string]string{}
+			record := func(r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				agents[r.URL.Path] = r.Header.Get("User-Agent")
+			}
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				record(r)
+				fmt.Fprint(w, "contents")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				record(r)
+				json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-user-agent")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			cfg := fakeDownloadConfig(tmpDir, ts.URL)
+			cfg.UserViperConfig.Set("useragent", tc.config)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("user-agent", tc.flag)
+
+			err = runDownload(context.Background(), cfg, flags, []string{})
+			assert.NoError(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, map[string]string{
+				"/solutions/latest": tc.expected,
+				"/files/file.txt":   tc.expected,
+			}, agents)
+		})
+	}
+}
+
+func TestDownloadEvents(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	out := &bytes.Buffer{}
+	Out = out
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, filepath.Base(r.URL.Path))
+	}
+	ts := fakeSolutionServer(handler, "a.txt", "subdir/bb.txt", "ccc.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-events")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("events", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	var events []map[string]interface{}
+	decoder := json.NewDecoder(out)
+	for decoder.More() {
+		var event map[string]interface{}
+		assert.NoError(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	if !assert.Equal(t, 5, len(events)) {
+		return
+	}
+	assert.Equal(t, map[string]interface{}{"event": "start", "files": float64(3)}, events[0])
+
+	files := map[string]interface{}{}
+	for _, event := range events[1:4] {
+		assert.Equal(t, "file", event["event"])
+		assert.Equal(t, filepath.Join(dir, event["name"].(string)), event["path"])
+		files[event["name"].(string)] = event["bytes"]
+	}
+	assert.Equal(t, map[string]interface{}{
+		"a.txt":                           float64(len("a.txt")),
+		filepath.Join("subdir", "bb.txt"): float64(len("bb.txt")),
+		"ccc.txt":                         float64(len("ccc.txt")),
+	}, files)
+
+	assert.Equal(t, map[string]interface{}{
+		"event":       "done",
+		"destination": dir,
+		"files":       float64(3),
+		"bytes":       float64(len("a.txt") + len("bb.txt") + len("ccc.txt")),
+	}, events[4])
+}
+
+func TestDownloadEventsError(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	out := &bytes.Buffer{}
+	Out = out
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-events-error")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("events", "true")
+	flags.Set("strict", "true")
+	flags.Set("max-retries", "0")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Equal(t, 2, len(lines)) {
+		assert.JSONEq(t, `{"event": "start", "files": 1}`, lines[0])
+		assert.JSONEq(t, fmt.Sprintf(`{"event": "error", "error": %q}`, err.Error()), lines[1])
+	}
+}
+
+func TestDownloadEventsWithJSON(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("events", "true")
+	flags.Set("json", "true")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig("/tmp", "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Equal(t, "--json and --events can't be used together", err.Error())
+	}
+}
+
+func TestDownloadPayloadCache(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		flags    map[string]string
+		expire   bool
+		requests int32
+	}{
+		{desc: "served from the cache", flags: map[string]string{"cache-ttl": "5m"}, requests: 1},
+		{desc: "off by default", requests: 2},
+		{desc: "bypassed with --no-cache", flags: map[string]string{"cache-ttl": "5m", "no-cache": "true"}, requests: 2},
+		{desc: "bypassed with --latest", flags: map[string]string{"cache-ttl": "5m", "latest": "true"}, requests: 2},
+		{desc: "bypassed with --force", flags: map[string]string{"cache-ttl": "5m", "force": "true"}, requests: 2},
+		{desc: "disabled without a TTL", flags: map[string]string{"cache-ttl": "0"}, requests: 2},
+		{desc: "expired after the TTL", flags: map[string]string{"cache-ttl": "5m"}, expire: true, requests: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			cacheDir, err := ioutil.TempDir("", "download-payload-cache")
+			defer os.RemoveAll(cacheDir)
+			assert.NoError(t, err)
+			oldCacheDir := payloadCacheDir
+			defer func() { payloadCacheDir = oldCacheDir }()
+			payloadCacheDir = func() string { return cacheDir }
+
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				json.NewEncoder(w).Encode(fakePayload("http://"+r.Host+"/files/", "file.txt"))
+			}))
+			defer ts.Close()
+
+			for i := 0; i < 2; i++ {
+				flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+				setupDownloadFlags(flags)
+				flags.Set("exercise", "bogus-exercise")
+				flags.Set("track", "bogus-track")
+				flags.Set("list-files-only", "true")
+				for name, value := range tc.flags {
+					flags.Set(name, value)
+				}
+
+				d, err := newDownload(context.Background(), flags, fakeDownloadConfig("/tmp", ts.URL).UserViperConfig)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"file.txt"}, d.payload.Solution.Files)
+
+				if tc.expire {
+					cached, _ := filepath.Glob(filepath.Join(cacheDir, "*.json"))
+					for _, path := range cached {
+						old := time.Now().Add(-time.Hour)
+						assert.NoError(t, os.Chtimes(path, old, old))
+					}
+				}
+			}
+
+			assert.Equal(t, tc.requests, atomic.LoadInt32(&requests))
+		})
+	}
+}
+
+func TestDownloadPayloadCacheKey(t *testing.T) {
+	oldCacheDir := payloadCacheDir
+	defer func() { payloadCacheDir = oldCacheDir }()
+	payloadCacheDir = func() string { return "/cache" }
+
+	base := download{token: "abc123", apibaseurl: "http://example.com", apisolutionspath: "/solutions", slug: "bogus-exercise", cacheTTL: time.Minute}
+	path := base.payloadCachePath()
+	assert.Equal(t, "/cache", filepath.Dir(path))
+
+	otherTrack, otherUUID, otherToken := base, base, base
+	otherTrack.track = "other-track"
+	otherUUID.slug, otherUUID.uuid = "", "bogus-id"
+	otherToken.token = "def456"
+	for _, other := range []download{otherTrack, otherUUID, otherToken} {
+		assert.NotEqual(t, path, other.payloadCachePath())
+	}
+}
+
+func TestDownloadPayloadCacheSkippedOnRefresh(t *testing.T) {
+	oldCacheDir := payloadCacheDir
+	defer func() { payloadCacheDir = oldCacheDir }()
+	payloadCacheDir = func() string { return "/cache" }
+
+	d := download{token: "abc123", apibaseurl: "http://example.com", apisolutionspath: "/solutions", uuid: "bogus-id", cacheTTL: time.Minute}
+	assert.NotEqual(t, "", d.payloadCachePath())
+
+	d.source = sourceExercise
+	assert.Equal(t, "", d.payloadCachePath())
+}
+
+func TestDownloadPostDownloadHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub hook is a POSIX shell script")
+	}
+
+	testCases := []struct {
+		desc   string
+		config string
+		flag   string
+	}{
+		{desc: "from the config", config: "sh ../../hook.sh"},
+		{desc: "flag wins over the config", config: "exit 1", flag: "sh ../../hook.sh"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "contents")
+			}
+			ts := fakeSolutionServer(handler, "file.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-hook")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			// The hook records where it ran, what it was told, and what it found.
+			script := `pwd > hook.txt; echo "$EXERCISM_DOWNLOAD_DIR" >> hook.txt; ls file.txt .exercism/metadata.json >> hook.txt`
+			err = ioutil.WriteFile(filepath.Join(tmpDir, "hook.sh"), []byte(script), os.FileMode(0644))
+			assert.NoError(t, err)
+
+			cfg := fakeDownloadConfig(tmpDir, ts.URL)
+			cfg.UserViperConfig.Set("postdownloadhook", tc.config)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("hook", tc.flag)
+
+			err = runDownload(context.Background(), cfg, flags, []string{})
+			assert.NoError(t, err)
+			assert.NotContains(t, Err.(*bytes.Buffer).String(), "hook failed")
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			b, err := ioutil.ReadFile(filepath.Join(dir, "hook.txt"))
+			assert.NoError(t, err)
+			// The temp dir may be reached through a symlink, as on macOS.
+			realDir, err := filepath.EvalSymlinks(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{realDir, dir, ".exercism/metadata.json", "file.txt", ""}, strings.Split(string(b), "\n"))
+		})
+	}
+}
+
+func TestDownloadPostDownloadHookFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the stub hook is a POSIX shell script")
+	}
+
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-hook-failure")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("hook", "echo installing; exit 3")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	errOut := Err.(*bytes.Buffer).String()
+	assert.Contains(t, errOut, "installing\n")
+	assert.Contains(t, errOut, "WARNING: The post-download hook failed: exit status 3. The downloaded files were kept.")
+
+	_, err = os.Stat(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+}
+
+func TestDownloadQuiet(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	testCases := []struct {
+		desc string
+		args []string
+		out  *regexp.Regexp
+	}{
+		{desc: "without --quiet", args: []string{}, out: regexp.MustCompile(`^Downloaded 1/1 files\nDownloaded 1 files .*\n.*bogus-exercise\n$`)},
+		{desc: "with --quiet", args: []string{"--quiet"}, out: regexp.MustCompile(`^[^\n]*bogus-exercise\n$`)},
+		{desc: "with -q", args: []string{"-q"}, out: regexp.MustCompile(`^[^\n]*bogus-exercise\n$`)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+			defer func(old bool) { quiet = old }(quiet)
+			quiet = false
+
+			tmpDir, err := ioutil.TempDir("", "download-quiet")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			// The flag belongs to the root command, and is read before any command runs.
+			flag := RootCmd.PersistentFlags().Lookup("quiet")
+			defer func() { flag.Value.Set("false"); flag.Changed = false }()
+			cmd := &cobra.Command{}
+			cmd.Flags().AddFlagSet(RootCmd.PersistentFlags())
+			err = cmd.Flags().Parse(tc.args)
+			assert.NoError(t, err)
+			RootCmd.PersistentPreRun(cmd, []string{})
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+			assert.Regexp(t, tc.out, Out.(*bytes.Buffer).String())
+		})
+	}
+}
+
+func TestDownloadStdout(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	var requested []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		fmt.Fprintf(w, "contents of %s", r.URL.Path)
+	}
+	ts := fakeSolutionServer(handler, "file.txt", "subdir/nested.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-stdout")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("stdout", "subdir/nested.txt")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "contents of /files/subdir/nested.txt", Out.(*bytes.Buffer).String())
+	assert.Equal(t, []string{"/files/subdir/nested.txt"}, requested)
+
+	entries, err := ioutil.ReadDir(tmpDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloadStdoutErrors(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		flags map[string]string
+		err   string
+	}{
+		{
+			desc:  "unknown file",
+			flags: map[string]string{"stdout": "missing.txt"},
+			err:   "'missing.txt' is not one of the solution's files",
+		},
+		{
+			desc:  "with --json",
+			flags: map[string]string{"stdout": "file.txt", "json": "true"},
+			err:   "--stdout can't be used with",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "contents")
+			}
+			ts := fakeSolutionServer(handler, "file.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-stdout-errors")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+			assert.Equal(t, "", Out.(*bytes.Buffer).String())
+
+			entries, err := ioutil.ReadDir(tmpDir)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestDownloadWithoutFiles(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		strict bool
+		err    string
+	}{
+		{desc: "reports that there is nothing to download"},
+		{desc: "fails with --strict", strict: true, err: "the solution has no files to download"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {})
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-without-files")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("strict", strconv.FormatBool(tc.strict))
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.err, err.Error())
+				}
+				assert.Equal(t, "", Out.(*bytes.Buffer).String())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Regexp(t, "^Solution has no files to download\n", Out.(*bytes.Buffer).String())
+		})
+	}
+}
+
+func TestDownloadPrintDestination(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		dryRun  bool
+		written bool
+	}{
+		{desc: "downloads", dryRun: false, written: true},
+		{desc: "with --dry-run", dryRun: true, written: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "contents")
+			})
+			mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+				// Someone else's solution, which would otherwise be announced.
+				payload := fakePayload(ts.URL+"/files/", "file.txt")
+				payload.Solution.User.Handle = "bob"
+				payload.Solution.User.IsRequester = false
+				json.NewEncoder(w).Encode(payload)
+			})
+
+			tmpDir, err := ioutil.TempDir("", "download-print-destination")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("print-destination", "true")
+			flags.Set("dry-run", strconv.FormatBool(tc.dryRun))
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "users", "bob", "bogus-track", "bogus-exercise")
+			assert.Equal(t, dir+"\n", Out.(*bytes.Buffer).String())
+			_, err = os.Stat(filepath.Join(dir, "file.txt"))
+			assert.Equal(t, tc.written, err == nil)
+		})
+	}
+}
+
+func TestDownloadPrintDestinationWithJSON(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("print-destination", "true")
+	flags.Set("json", "true")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig("/tmp", "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "--print-destination can't be used with --json")
+	}
+}
+
+func TestDownloadTimeoutPerFile(t *testing.T) {
+	testCases := []struct {
+		desc   string
+		strict bool
+		err    string
+	}{
+		{desc: "skips the slow file"},
+		{desc: "fails with --strict", strict: true, err: "unable to download 'slow.txt': it took longer than the --timeout-per-file of 50ms"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/files/slow.txt" {
+					select {
+					case <-r.Context().Done():
+					case <-time.After(5 * time.Second):
+					}
+					return
+				}
+				fmt.Fprint(w, "contents")
+			}
+			ts := fakeSolutionServer(handler, "fast.txt", "slow.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-timeout-per-file")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("timeout-per-file", "50ms")
+			flags.Set("concurrency", "1")
+			flags.Set("strict", strconv.FormatBool(tc.strict))
+
+			start := time.Now()
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			assert.True(t, time.Since(start) < 3*time.Second, "the per-file deadline didn't fire")
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			_, statErr := os.Stat(filepath.Join(dir, "slow.txt"))
+			assert.True(t, os.IsNotExist(statErr))
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.err, err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, Err.(*bytes.Buffer).String(), "WARNING: Skipping 'slow.txt', it took longer than the --timeout-per-file of 50ms.")
+			_, err = os.Stat(filepath.Join(dir, "fast.txt"))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestDownloadNegativeTimeoutPerFile(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("timeout-per-file", "-1s")
+
+	_, err := newDownload(context.Background(), flags, fakeDownloadConfig("/tmp", "http://example.com").UserViperConfig)
+	if assert.Error(t, err) {
+		assert.Equal(t, "--timeout-per-file must not be negative", err.Error())
+	}
+}
+
+func TestDownloadRetryConfig(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		config   map[string]interface{}
+		flags    map[string]string
+		expected api.RetryPolicy
+	}{
+		{
+			desc:     "defaults",
+			expected: api.RetryPolicy{MaxRetries: 2, BaseDelay: api.DefaultRetryBaseDelay, MaxDelay: api.DefaultRetryMaxDelay},
+		},
+		{
+			desc:     "from the config",
+			config:   map[string]interface{}{"maxretries": 5, "retrydelay": "100ms", "maxretrywait": "2s"},
+			expected: api.RetryPolicy{MaxRetries: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 2 * time.Second},
+		},
+		{
+			desc:     "retries turned off in the config",
+			config:   map[string]interface{}{"maxretries": 0},
+			expected: api.RetryPolicy{MaxRetries: 0, BaseDelay: api.DefaultRetryBaseDelay, MaxDelay: api.DefaultRetryMaxDelay},
+		},
+		{
+			desc:     "flags win over the config",
+			config:   map[string]interface{}{"maxretries": 5, "retrydelay": "100ms", "maxretrywait": "2s"},
+			flags:    map[string]string{"max-retries": "1", "retry-delay": "1s", "max-retry-wait": "3s"},
+			expected: api.RetryPolicy{MaxRetries: 1, BaseDelay: time.Second, MaxDelay: 3 * time.Second},
+		},
+		{
+			desc:     "flags win over the config one at a time",
+			config:   map[string]interface{}{"maxretries": 5, "retrydelay": "100ms"},
+			flags:    map[string]string{"max-retries": "0"},
+			expected: api.RetryPolicy{MaxRetries: 0, BaseDelay: 100 * time.Millisecond, MaxDelay: api.DefaultRetryMaxDelay},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {}, "file.txt")
+			defer ts.Close()
+
+			cfg := fakeDownloadConfig("/tmp", ts.URL)
+			for key, value := range tc.config {
+				cfg.UserViperConfig.Set(key, value)
+			}
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("list-files-only", "true")
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			d, err := newDownload(context.Background(), flags, cfg.UserViperConfig)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expected, d.client.Retry)
+			}
+		})
+	}
+}
+
+func TestDownloadRetryConfigFile(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		config        string
+		expectedDelay time.Duration
+		expectedWait  time.Duration
+		err           string
+	}{
+		{
+			desc:          "bare numbers are seconds",
+			config:        `{"retrydelay": 2, "maxretrywait": 30}`,
+			expectedDelay: 2 * time.Second,
+			expectedWait:  30 * time.Second,
+		},
+		{
+			desc:          "fractions of a second",
+			config:        `{"retrydelay": 0.5, "maxretrywait": "1.5"}`,
+			expectedDelay: 500 * time.Millisecond,
+			expectedWait:  1500 * time.Millisecond,
+		},
+		{
+			desc:          "durations with units",
+			config:        `{"retrydelay": "250ms", "maxretrywait": "1m"}`,
+			expectedDelay: 250 * time.Millisecond,
+			expectedWait:  time.Minute,
+		},
+		{
+			desc:   "neither",
+			config: `{"retrydelay": "soon"}`,
+			err:    "retrydelay in the user config must be a number of seconds or a duration such as 500ms, not 'soon'",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {}, "file.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-retry-config")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			var fields map[string]interface{}
+			err = json.Unmarshal([]byte(tc.config), &fields)
+			assert.NoError(t, err)
+			fields["token"] = "abc123"
+			fields["workspace"] = tmpDir
+			fields["apibaseurl"] = ts.URL
+			b, err := json.Marshal(fields)
+			assert.NoError(t, err)
+			path := filepath.Join(tmpDir, "user.json")
+			err = ioutil.WriteFile(path, b, os.FileMode(0600))
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("config", path)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("list-files-only", "true")
+
+			v, err := downloadUserConfig(tmpDir, flags)
+			assert.NoError(t, err)
+
+			d, err := newDownload(context.Background(), flags, v)
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.err, err.Error())
+					assert.True(t, errors.Is(err, ErrMissingConfig))
+				}
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.expectedDelay, d.client.Retry.BaseDelay)
+				assert.Equal(t, tc.expectedWait, d.client.Retry.MaxDelay)
+			}
+		})
+	}
+}
+
+func TestDownloadGitCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is needed to commit the download")
+	}
+
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file.txt", "subdir/nested.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-git-commit")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmpDir
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+		return string(out)
+	}
+	git("init", "--quiet")
+	git("config", "user.name", "Alice")
+	git("config", "user.email", "alice@example.com")
+	// Something unrelated that's staged stays out of the commit.
+	err = ioutil.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("notes"), os.FileMode(0644))
+	assert.NoError(t, err)
+	git("add", "notes.txt")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("git-commit", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+	assert.NotContains(t, Err.(*bytes.Buffer).String(), "WARNING")
+
+	assert.Equal(t, "Download bogus-track/bogus-exercise\n", git("log", "--format=%s"))
+	assert.Equal(t, strings.Join([]string{
+		"bogus-track/bogus-exercise/.exercism/metadata.json",
+		"bogus-track/bogus-exercise/file.txt",
+		"bogus-track/bogus-exercise/subdir/nested.txt",
+		"",
+	}, "\n"), git("show", "--name-only", "--format="))
+	assert.Equal(t, "A  notes.txt\n", git("status", "--porcelain"))
+}
+
+func TestDownloadGitCommitOutsideRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is needed to look for a repository")
+	}
+
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-git-commit-outside")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("git-commit", "true")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+	assert.Regexp(t, "WARNING: The download wasn't committed: '.*bogus-exercise' isn't in a git repository.", Err.(*bytes.Buffer).String())
+
+	_, err = os.Stat(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+	assert.NoError(t, err)
+}
+
+func TestDownloadVerifyOnly(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		local    map[string]string
+		expected string
+		err      string
+	}{
+		{
+			desc:     "everything matches",
+			local:    map[string]string{"a.txt": "contents of a.txt", "b.txt": "contents of b.txt", "c.txt": "contents of c.txt"},
+			expected: "match   a.txt\nmatch   b.txt\nmatch   c.txt\n",
+		},
+		{
+			desc:     "differences",
+			local:    map[string]string{"a.txt": "contents of a.txt", "b.txt": "changed", "extra.txt": "mine"},
+			expected: "match   a.txt\ndiffers b.txt\nmissing c.txt\n",
+			err:      "2 of 3 files don't match the solution",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "contents of %s", strings.TrimPrefix(r.URL.Path, "/files/"))
+			}
+			ts := fakeSolutionServer(handler, "a.txt", "b.txt", "c.txt")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-verify-only")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			err = os.MkdirAll(dir, os.FileMode(0755))
+			assert.NoError(t, err)
+			for name, contents := range tc.local {
+				err = ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), os.FileMode(0644))
+				assert.NoError(t, err)
+			}
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("verify-only", "true")
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.err)
+			}
+			assert.Equal(t, tc.expected, Out.(*bytes.Buffer).String())
+
+			// Nothing was changed, or added.
+			entries, err := ioutil.ReadDir(dir)
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.local), len(entries))
+			for name, contents := range tc.local {
+				b, err := ioutil.ReadFile(filepath.Join(dir, name))
+				assert.NoError(t, err)
+				assert.Equal(t, contents, string(b))
+			}
+		})
+	}
+}
+
+func TestDownloadSourceQuery(t *testing.T) {
+	var query netURL.Values
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	payload := func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	}
+	mux.HandleFunc("/solutions/latest", payload)
+	mux.HandleFunc("/solutions/bogus-id", payload)
+
+	tmpDir, err := ioutil.TempDir("", "download-source")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	usrCfg := fakeDownloadConfig(tmpDir, ts.URL).UserViperConfig
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	_, err = newDownload(context.Background(), flags, usrCfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "flags", query.Get("source"))
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	metadata := &workspace.ExerciseMetadata{ID: "bogus-id", Track: "bogus-track", ExerciseSlug: "bogus-exercise"}
+	err = metadata.Write(dir)
+	assert.NoError(t, err)
+
+	flags = pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupRefreshFlags(flags)
+	_, err = newDownloadFromExercise(context.Background(), dir, flags, usrCfg)
+	assert.NoError(t, err)
+	assert.Equal(t, "exercise", query.Get("source"))
+}
+
+func TestDownloadRejectHTML(t *testing.T) {
+	const page = "<!DOCTYPE html>\n<html><head><title>Sign in</title></head></html>"
+
+	testCases := []struct {
+		desc   string
+		file   string
+		reject bool
+		err    string
+	}{
+		{desc: "rejects an HTML page for source", file: "main.go", reject: true, err: "refusing to write 'main.go', the server sent an HTML page instead of the file"},
+		{desc: "writes it without --reject-html", file: "main.go", reject: false},
+		{desc: "writes HTML files", file: "index.html", reject: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/files/other.go" {
+					fmt.Fprint(w, "package main\n")
+					return
+				}
+				fmt.Fprint(w, page)
+			}
+			ts := fakeSolutionServer(handler, "other.go", tc.file)
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-reject-html")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("reject-html", strconv.FormatBool(tc.reject))
+
+			err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			if tc.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, tc.err, err.Error())
+				}
+				_, err = os.Stat(filepath.Join(dir, tc.file))
+				assert.True(t, os.IsNotExist(err))
+				_, err = os.Stat(filepath.Join(dir, tc.file+".part"))
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			assert.NoError(t, err)
+			b, err := ioutil.ReadFile(filepath.Join(dir, tc.file))
+			assert.NoError(t, err)
+			assert.Equal(t, page, string(b))
+			b, err = ioutil.ReadFile(filepath.Join(dir, "other.go"))
+			assert.NoError(t, err)
+			assert.Equal(t, "package main\n", string(b))
+		})
+	}
+}
+
+func TestDownloadOnlyFiles(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	var requested []string
+	var mu sync.Mutex
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		fmt.Fprintf(w, "contents of %s", r.URL.Path)
+	}
+	ts := fakeSolutionServer(handler, "file.txt", "file_test.txt", "subdir/nested.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-only-files")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("file", "file_test.txt")
+	flags.Set("file", "subdir/nested.txt")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+	sort.Strings(requested)
+	assert.Equal(t, []string{"/files/file_test.txt", "/files/subdir/nested.txt"}, requested)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	b, err := ioutil.ReadFile(filepath.Join(dir, "file_test.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "contents of /files/file_test.txt", string(b))
+	b, err = ioutil.ReadFile(filepath.Join(dir, "subdir", "nested.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "contents of /files/subdir/nested.txt", string(b))
+	_, err = os.Stat(filepath.Join(dir, "file.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadUnknownOnlyFile(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request for %s", r.URL.Path)
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-unknown-only-file")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("file", "file.txt")
+	flags.Set("file", "missing.txt")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "'missing.txt' is not one of the solution's files", err.Error())
+	}
+	_, err = os.Stat(filepath.Join(tmpDir, "bogus-track", "bogus-exercise"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadLatest(t *testing.T) {
+	var requested string
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	payload := func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "file.txt"))
+	}
+	mux.HandleFunc("/solutions/latest", payload)
+	mux.HandleFunc("/solutions/bogus-id", payload)
+
+	tmpDir, err := ioutil.TempDir("", "download-latest")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		desc     string
+		flags    map[string]string
+		expected string
+	}{
+		{
+			desc:     "latest",
+			flags:    map[string]string{"exercise": "bogus-exercise", "latest": "true"},
+			expected: "/solutions/latest",
+		},
+		{
+			desc:     "latest by default",
+			flags:    map[string]string{"exercise": "bogus-exercise"},
+			expected: "/solutions/latest",
+		},
+		{
+			desc:     "uuid",
+			flags:    map[string]string{"uuid": "bogus-id"},
+			expected: "/solutions/bogus-id",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			requested = ""
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			_, err := newDownload(context.Background(), flags, fakeDownloadConfig(tmpDir, ts.URL).UserViperConfig)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, requested)
+		})
+	}
+}
+
+func TestDownloadLatestWithUUID(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		flags map[string]string
+	}{
+		{desc: "uuid", flags: map[string]string{"uuid": "bogus-id", "latest": "true"}},
+		{desc: "solution URL", flags: map[string]string{"url": "https://exercism.io/solutions/bogus-id", "latest": "true"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			_, err := newDownload(context.Background(), flags, fakeDownloadConfig(os.TempDir(), "http://example.com").UserViperConfig)
+			if assert.Error(t, err) {
+				assert.Equal(t, "--latest and --uuid cannot be used together, a solution UUID picks one solution", err.Error())
+			}
+		})
+	}
+}
+
+func TestDownloadCompareUUID(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	// The second iteration changes main.go, drops notes.txt, and adds main_test.go.
+	iterations := map[string]map[string]string{
+		"first-id": {
+			"main.go":   "package main\n\nfunc main() {\n}\n",
+			"notes.txt": "todo\n",
+			"README.md": "# Bogus\n",
+		},
+		"second-id": {
+			"main.go":      "package main\n\nfunc main() {\n\tprintln()\n}\n",
+			"main_test.go": "package main\n",
+			"README.md":    "# Bogus\n",
+		},
+	}
+
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	for id, files := range iterations {
+		id, files := id, files
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		mux.HandleFunc("/solutions/"+id, func(w http.ResponseWriter, r *http.Request) {
+			payload := fakePayload(ts.URL+"/files/"+id+"/", names...)
+			payload.Solution.ID = id
+			json.NewEncoder(w).Encode(payload)
+		})
+		mux.HandleFunc("/files/"+id+"/", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, files[strings.TrimPrefix(r.URL.Path, "/files/"+id+"/")])
+		})
+	}
+
+	tmpDir, err := ioutil.TempDir("", "download-compare")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("uuid", "first-id")
+	flags.Set("compare-uuid", "second-id")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	expected := strings.Join([]string{
+		"--- first-id/main.go",
+		"+++ second-id/main.go",
+		"@@ -1,4 +1,5 @@",
+		" package main",
+		" ",
+		" func main() {",
+		"+\tprintln()",
+		" }",
+		"--- /dev/null",
+		"+++ second-id/main_test.go",
+		"@@ -0,0 +1 @@",
+		"+package main",
+		"--- first-id/notes.txt",
+		"+++ /dev/null",
+		"@@ -1 +0,0 @@",
+		"-todo",
+		"",
+	}, "\n")
+	assert.Equal(t, expected, Out.(*bytes.Buffer).String())
+
+	entries, err := ioutil.ReadDir(tmpDir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloadCompareUUIDErrors(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		flags map[string]string
+		err   string
+	}{
+		{
+			desc:  "without --uuid",
+			flags: map[string]string{"exercise": "bogus-exercise", "compare-uuid": "second-id"},
+			err:   "--compare-uuid needs a solution --uuid to compare with",
+		},
+		{
+			desc:  "with --json",
+			flags: map[string]string{"uuid": "first-id", "compare-uuid": "second-id", "json": "true"},
+			err:   "--compare-uuid can't be used with --json, --events, --dry-run, --list-files-only, --stdout, --verify-only, or --print-destination",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			for name, value := range tc.flags {
+				flags.Set(name, value)
+			}
+
+			_, err := newDownload(context.Background(), flags, fakeDownloadConfig(os.TempDir(), "http://example.com").UserViperConfig)
+			if assert.Error(t, err) {
+				assert.Equal(t, tc.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestDownloadCompressedFiles(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	const contents = "package main\n\nfunc main() {\n}\n"
+	compress := func(encoding string) []byte {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		switch encoding {
+		case "gzip":
+			w = gzip.NewWriter(&buf)
+		case "deflate":
+			w = zlib.NewWriter(&buf)
+		}
+		io.WriteString(w, contents)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	var mu sync.Mutex
+	accepted := map[string]string{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		accepted[r.URL.Path] = r.Header.Get("Accept-Encoding")
+		mu.Unlock()
+
+		encoding := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/files/"), ".go")
+		if encoding == "plain" {
+			fmt.Fprint(w, contents)
+			return
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		w.Write(compress(encoding))
+	}
+	ts := fakeSolutionServer(handler, "gzip.go", "deflate.go", "plain.go")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-compressed")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	for _, name := range []string{"gzip.go", "deflate.go", "plain.go"} {
+		b, err := ioutil.ReadFile(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", name))
+		assert.NoError(t, err)
+		assert.Equal(t, contents, string(b), name)
+		assert.Equal(t, "gzip, deflate", accepted["/files/"+name])
+	}
+}
+
+func TestDownloadUnsupportedEncoding(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		fmt.Fprint(w, "compressed")
+	}
+	ts := fakeSolutionServer(handler, "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-unsupported-encoding")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "unable to download 'file.txt': the server sent it with an unsupported encoding 'br'", err.Error())
+	}
+	_, err = os.Stat(filepath.Join(tmpDir, "bogus-track", "bogus-exercise", "file.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestDownloadDuplicateFiles(t *testing.T) {
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	var mu sync.Mutex
+	requested := map[string]int{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		fmt.Fprintf(w, "contents of %s", r.URL.Path)
+	}
+	ts := fakeSolutionServer(handler, "file.txt", "other.txt", "file.txt")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-duplicates")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("json", "true")
+
+	out := &bytes.Buffer{}
+	Out = out
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]int{"/files/file.txt": 1, "/files/other.txt": 1}, requested)
+	assert.Contains(t, Err.(*bytes.Buffer).String(), "WARNING: The solution lists 'file.txt' more than once, it is only downloaded once.")
+
+	var summary DownloadResult
+	err = json.Unmarshal(out.Bytes(), &summary)
+	assert.NoError(t, err)
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	assert.Equal(t, []string{filepath.Join(dir, "file.txt"), filepath.Join(dir, "other.txt")}, summary.Files)
+}
+
+func TestDownloadRename(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content of "+strings.TrimPrefix(r.URL.Path, "/files/"))
+	}, "leap.example", "lib/helper.example", "leap_test.go", "README.md")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-rename")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("rename", ".example=.go")
+	flags.Set("rename", "md=markdown")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	expected := map[string]string{
+		"leap.go":         "content of leap.example",
+		"lib/helper.go":   "content of lib/helper.example",
+		"leap_test.go":    "content of leap_test.go",
+		"README.markdown": "content of README.md",
+	}
+	for name, contents := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		assert.Equal(t, contents, string(b))
+	}
+	for _, name := range []string{"leap.example", "lib/helper.example", "README.md"} {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	metadata, err := workspace.NewExerciseMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"leap.example":       "leap.go",
+		"lib/helper.example": "lib/helper.go",
+		"README.md":          "README.markdown",
+	}, metadata.Renamed)
+}
+
+func TestDownloadFileSums(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content of "+strings.TrimPrefix(r.URL.Path, "/files/"))
+	}, "leap.go", "lib/helper.go")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-sums")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	metadata, err := workspace.NewExerciseMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"leap.go":       "38a7019391975d7fd0d303061a50263ee235ccb52b28fe97c6d63a4f8d1dc6a8",
+		"lib/helper.go": "ab73b50b13a407932a88398d0fcd408b7f4b0c5a8b38fc9d3334b08999be0597",
+	}, metadata.SHA256)
+
+	for name, sum := range metadata.SHA256 {
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		assert.NoError(t, err)
+		expected := sha256.Sum256(b)
+		assert.Equal(t, hex.EncodeToString(expected[:]), sum, name)
+	}
+}
+
+func TestDownloadWithoutRename(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	ts := fakeSolutionServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "content")
+	}, "leap.example")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-rename")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+
+	err = runDownload(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+	_, err = os.Stat(filepath.Join(dir, "leap.example"))
+	assert.NoError(t, err)
+
+	metadata, err := workspace.NewExerciseMetadata(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, metadata.Renamed)
+}
+
+func TestDownloadInvalidRename(t *testing.T) {
+	for _, mapping := range []string{".example", ".example=", "=.go", ".=.go"} {
+		t.Run(mapping, func(t *testing.T) {
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadFlags(flags)
+			flags.Set("exercise", "bogus-exercise")
+			flags.Set("rename", mapping)
+
+			err := runDownload(context.Background(), fakeDownloadConfig(os.TempDir(), "http://example.com"), flags, []string{})
+			if assert.Error(t, err) {
+				assert.Equal(t, "--rename '"+mapping+"' must map one extension to another, as in .example=.go", err.Error())
+			}
+		})
+	}
+}
+
+func TestDownloadInvalidConcurrency(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("exercise", "bogus-exercise")
+	flags.Set("concurrency", "0")
+
+	err := runDownload(context.Background(), fakeDownloadConfig("/home/whatever", "http://example.com"), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "--concurrency must be at least 1", err.Error())
+	}
+}
+
+func fakeDownloadServer(requestor, teamSlug string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	mux.HandleFunc("/file-1.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "this is file 1")
+	})
+
+	mux.HandleFunc("/subdir/file-2.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "this is file 2")
+	})
+
+	mux.HandleFunc("/file-3.txt", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "")
+	})
+
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		team := "null"
+		if teamSlug := r.FormValue("team_id"); teamSlug != "" {
+			team = fmt.Sprintf(`{"name": "Bogus Team", "slug": "%s"}`, teamSlug)
+		}
+		payloadBody := fmt.Sprintf(payloadTemplate, requestor, team, server.URL+"/")
+		fmt.Fprint(w, payloadBody)
+	})
+	mux.HandleFunc("/solutions/bogus-id", func(w http.ResponseWriter, r *http.Request) {
+		payloadBody := fmt.Sprintf(payloadTemplate, requestor, "null", server.URL+"/")
+		fmt.Fprint(w, payloadBody)
+	})
+
+	return server
+}
+
+func assertDownloadedCorrectFiles(t *testing.T, targetDir string) {
+	expectedFiles := []struct {
+		desc     string
+		path     string
+		contents string
+	}{
+		{
+			desc:     "a file in the exercise root directory",
+			path:     filepath.Join(targetDir, "bogus-track", "bogus-exercise", "file-1.txt"),
+			contents: "this is file 1",
+		},
+		{
+			desc:     "a file in a subdirectory",
+			path:     filepath.Join(targetDir, "bogus-track", "bogus-exercise", "subdir", "file-2.txt"),
+			contents: "this is file 2",
+		},
+	}
+
+	for _, file := range expectedFiles {
+		t.Run(file.desc, func(t *testing.T) {
+			b, err := ioutil.ReadFile(file.path)
+			assert.NoError(t, err)
+			assert.Equal(t, file.contents, string(b))
+		})
+	}
+
+	path := filepath.Join(targetDir, "bogus-track", "bogus-exercise", "file-3.txt")
+	_, err := os.Lstat(path)
+	assert.True(t, os.IsNotExist(err), "It should not write the file if empty.")
+}
+
+func TestDownloadError(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"error": {"type": "error", "message": "test error"}}`)
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "submit-err-tmp-dir")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	v := viper.New()
+	v.Set("token", "abc123")
+	v.Set("workspace", tmpDir)
+	v.Set("apibaseurl", ts.URL)
+
+	cfg := config.Config{
+		Persister:       config.InMemoryPersister{},
+		UserViperConfig: v,
+		DefaultBaseURL:  "http://example.com",
+	}
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadFlags(flags)
+	flags.Set("uuid", "value")
+
+	err = runDownload(context.Background(), cfg, flags, []string{})
+
+	assert.Equal(t, "test error", err.Error())
+
+}
+
+// fakeDownloadConfig returns a config pointing at the given workspace and API.
+func fakeDownloadConfig(workspace, apibaseurl string) config.Config {
+	v := viper.New()
+	v.Set("workspace", workspace)
+	v.Set("apibaseurl", apibaseurl)
+	v.Set("token", "abc123")
+	return config.Config{
+		UserViperConfig: v,
+	}
+}
+
+// fakeSolutionServer serves a personal solution listing the given files.
+// Requests for the files themselves are passed to handler.
+func fakeSolutionServer(handler http.HandlerFunc, files ...string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	mux.HandleFunc("/files/", handler)
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(fakePayload(server.URL+"/files/", files...))
+	})
+	return server
+}
+
+// fakePayload builds a personal solution payload for bogus-track/bogus-exercise.
+// The solution is on the site that serves its files, or on example.com.
+func fakePayload(baseURL string, files ...string) downloadPayload {
+	site := "http://example.com"
+	if u, err := netURL.Parse(baseURL); err == nil && u.Host != "" {
+		site = u.Scheme + "://" + u.Host
+	}
 
+	var payload downloadPayload
+	payload.Solution.ID = "bogus-id"
+	payload.Solution.URL = site + "/solutions/bogus-id"
+	payload.Solution.User.Handle = "alice"
+	payload.Solution.User.IsRequester = true
+	payload.Solution.Exercise.ID = "bogus-exercise"
+	payload.Solution.Exercise.Track.ID = "bogus-track"
+	payload.Solution.FileDownloadBaseURL = baseURL
+	payload.Solution.Files = files
+	return payload
 }
 
 const payloadTemplate = `
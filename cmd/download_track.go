@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/exercism/cli/api"
+	"github.com/exercism/cli/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// downloadTrackCmd downloads the solutions to every exercise of a track.
+var downloadTrackCmd = &cobra.Command{
+	Use:   "download-track",
+	Short: "Download every exercise of a track.",
+	Long: `Download your solution to every exercise of a track.
+
+The exercises are downloaded one after the other, each to its own
+directory in the workspace. A failed download doesn't stop the rest,
+unless you pass --fail-fast. Once they're done, the exercises that
+couldn't be downloaded are listed.
+
+Which exercises were downloaded is recorded in a manifest file as
+they go. Run the command again with --resume to skip those, and only
+retry the ones that failed or weren't reached, continuing from any
+files they already have.
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.NewConfig()
+
+		v, err := loadUserConfig(cfg.Dir, cmd.Flags())
+		if err != nil {
+			return err
+		}
+		cfg.UserViperConfig = v
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		return runDownloadTrack(ctx, cfg, cmd.Flags(), args)
+	},
+}
+
+// trackExercise is an exercise of a track, as listed by the API.
+type trackExercise struct {
+	Slug string `json:"slug"`
+}
+
+// The outcomes of downloading an exercise, as recorded in a track manifest.
+const (
+	manifestDownloaded = "downloaded"
+	manifestFailed     = "failed"
+)
+
+// trackManifest records how a download of a track went, exercise by exercise,
+// so that it can be resumed.
+type trackManifest struct {
+	Track     string                        `json:"track"`
+	Exercises map[string]trackManifestEntry `json:"exercises"`
+}
+
+type trackManifestEntry struct {
+	Status      string `json:"status"`
+	Destination string `json:"destination,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// loadTrackManifest reads the manifest of an earlier download of the track.
+// Without one, the manifest is empty.
+func loadTrackManifest(path, track string) (*trackManifest, error) {
+	manifest := &trackManifest{Track: track, Exercises: map[string]trackManifestEntry{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, manifest); err != nil {
+		return nil, fmt.Errorf("unable to parse the manifest '%s': %s", path, err)
+	}
+	if manifest.Track != track {
+		return nil, fmt.Errorf("the manifest '%s' is of the %s track, not %s", path, manifest.Track, track)
+	}
+	if manifest.Exercises == nil {
+		manifest.Exercises = map[string]trackManifestEntry{}
+	}
+	return manifest, nil
+}
+
+// write saves the manifest, replacing the file in one go so that an
+// interrupted write doesn't lose the earlier progress.
+func (m *trackManifest) write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.FileMode(0755)); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", b, os.FileMode(0644)); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func runDownloadTrack(ctx context.Context, cfg config.Config, flags *pflag.FlagSet, args []string) error {
+	usrCfg := cfg.UserViperConfig
+	if err := validateUserConfig(usrCfg); err != nil {
+		return err
+	}
+
+	track, err := flags.GetString("track")
+	if err != nil {
+		return err
+	}
+	if track == "" {
+		return errors.New("need a --track to download")
+	}
+	failFast, err := flags.GetBool("fail-fast")
+	if err != nil {
+		return err
+	}
+	resume, err := flags.GetBool("resume")
+	if err != nil {
+		return err
+	}
+	manifestPath, err := flags.GetString("manifest")
+	if err != nil {
+		return err
+	}
+	if manifestPath == "" {
+		manifestPath = filepath.Join(userWorkspace(usrCfg), fmt.Sprintf(".download-track-%s.json", track))
+	}
+	manifestPath = config.Expand(manifestPath)
+
+	manifest := &trackManifest{Track: track, Exercises: map[string]trackManifestEntry{}}
+	if resume {
+		if manifest, err = loadTrackManifest(manifestPath, track); err != nil {
+			return err
+		}
+	}
+
+	// The exercises are listed over the same connection as they're downloaded.
+	client, err := newAPIClient(flags, usrCfg)
+	if err != nil {
+		return err
+	}
+	exercises, err := requestTrackExercises(ctx, client, track)
+	if err != nil {
+		return redactToken(err, usrCfg.GetString("token"))
+	}
+
+	var failed []string
+	downloaded := 0
+	skipped := 0
+	for _, exercise := range exercises {
+		if resume && manifest.Exercises[exercise.Slug].Status == manifestDownloaded {
+			skipped++
+			continue
+		}
+
+		downloadFlags := downloadFlagSet(flags)
+		downloadFlags.Set("exercise", exercise.Slug)
+
+		summary, err := downloadSolution(ctx, cfg, downloadFlags)
+		if err != nil {
+			err = redactToken(err, usrCfg.GetString("token"))
+			failed = append(failed, fmt.Sprintf("%s: %s", exercise.Slug, err))
+			manifest.Exercises[exercise.Slug] = trackManifestEntry{Status: manifestFailed, Error: err.Error()}
+			if err := manifest.write(manifestPath); err != nil {
+				warnf("The manifest '%s' couldn't be written: %s.", manifestPath, err)
+			}
+			if failFast || ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		downloaded++
+		fmt.Fprintf(Out, "%s\n", summary.Destination)
+		manifest.Exercises[exercise.Slug] = trackManifestEntry{Status: manifestDownloaded, Destination: summary.Destination}
+		if err := manifest.write(manifestPath); err != nil {
+			warnf("The manifest '%s' couldn't be written: %s.", manifestPath, err)
+		}
+	}
+
+	if skipped > 0 {
+		fmt.Fprintf(Err, "\nSkipped %d exercises that '%s' records as downloaded\n", skipped, manifestPath)
+		downloaded += skipped
+	}
+
+	fmt.Fprintf(Err, "\nDownloaded %d of %d exercises in the %s track\n", downloaded, len(exercises), track)
+	if len(failed) == 0 {
+		return nil
+	}
+	fmt.Fprintf(Err, "\nThese exercises could not be downloaded:\n\n")
+	for _, failure := range failed {
+		fmt.Fprintf(Err, "    %s\n", failure)
+	}
+	return fmt.Errorf("%d of %d exercises could not be downloaded", len(failed), len(exercises))
+}
+
+// requestTrackExercises asks the API for the exercises of the track.
+func requestTrackExercises(ctx context.Context, client *api.Client, track string) ([]trackExercise, error) {
+	url := fmt.Sprintf("%s/tracks/%s/exercises", client.APIBaseURL, track)
+	req, err := client.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, withKind(ErrNetwork, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, decodedAPIError(res)
+	}
+
+	var body struct {
+		Exercises []trackExercise `json:"exercises"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("unable to parse the exercises of the %s track: %s", track, err)
+	}
+	return body.Exercises, nil
+}
+
+func setupDownloadTrackFlags(flags *pflag.FlagSet) {
+	flags.StringP("track", "t", "", "the track ID")
+	flags.BoolP("fail-fast", "", false, "stop at the first exercise that can't be downloaded")
+	flags.BoolP("force", "F", false, "overwrite existing exercise directories")
+	flags.BoolP("resume", "", false, "skip the exercises that the manifest records as downloaded")
+	flags.StringP("manifest", "", "", "file recording which exercises were downloaded (default .download-track-<track>.json in the workspace)")
+	setupUserConfigFlags(flags)
+	setupClientFlags(flags)
+}
+
+func init() {
+	RootCmd.AddCommand(downloadTrackCmd)
+	setupDownloadTrackFlags(downloadTrackCmd.Flags())
+}
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTrackServer lists the exercises of the bogus-track track, and serves
+// a solution with one file for each of them except the failing ones.
+func fakeTrackServer(exercises []string, failing ...string) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	mux.HandleFunc("/tracks/bogus-track/exercises", func(w http.ResponseWriter, r *http.Request) {
+		listed := make([]trackExercise, 0, len(exercises))
+		for _, slug := range exercises {
+			listed = append(listed, trackExercise{Slug: slug})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"exercises": listed})
+	})
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "contents")
+	})
+	mux.HandleFunc("/solutions/latest", func(w http.ResponseWriter, r *http.Request) {
+		slug := r.URL.Query().Get("exercise_id")
+		for _, f := range failing {
+			if f == slug {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"error": {"type": "not_found", "message": "no solution for %s"}}`, slug)
+				return
+			}
+		}
+		payload := fakePayload(server.URL+"/files/", "file.txt")
+		payload.Solution.Exercise.ID = slug
+		json.NewEncoder(w).Encode(payload)
+	})
+	return server
+}
+
+func TestDownloadTrack(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := fakeTrackServer([]string{"hello", "leap", "bob"})
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-track")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadTrackFlags(flags)
+	flags.Set("track", "bogus-track")
+	defer func(old bool) { quiet = old }(quiet)
+	quiet = true
+
+	err = runDownloadTrack(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	var expected []string
+	for _, slug := range []string{"hello", "leap", "bob"} {
+		dir := filepath.Join(tmpDir, "bogus-track", slug)
+		expected = append(expected, dir)
+		_, err = os.Stat(filepath.Join(dir, "file.txt"))
+		assert.NoError(t, err, slug)
+	}
+	assert.Equal(t, strings.Join(expected, "\n")+"\n", Out.(*bytes.Buffer).String())
+	assert.Contains(t, Err.(*bytes.Buffer).String(), "Downloaded 3 of 3 exercises in the bogus-track track")
+}
+
+func TestDownloadTrackListsWithClientSettings(t *testing.T) {
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	var paths, agents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		agents = append(agents, r.UserAgent())
+		json.NewEncoder(w).Encode(map[string]interface{}{"exercises": []trackExercise{}})
+	}))
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-track")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadTrackFlags(flags)
+	flags.Set("track", "bogus-track")
+
+	cfg := fakeDownloadConfig(tmpDir, ts.URL+"/ ")
+	cfg.UserViperConfig.Set("useragent", "bogus-agent")
+
+	err = runDownloadTrack(context.Background(), cfg, flags, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/tracks/bogus-track/exercises"}, paths)
+	assert.Equal(t, []string{"bogus-agent"}, agents)
+}
+
+func TestDownloadTrackFailures(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		failFast   bool
+		downloaded []string
+		report     string
+		err        string
+	}{
+		{
+			desc:       "continues past failures",
+			failFast:   false,
+			downloaded: []string{"hello", "bob"},
+			report:     "Downloaded 2 of 4 exercises",
+			err:        "2 of 4 exercises could not be downloaded",
+		},
+		{
+			desc:       "stops at the first failure with --fail-fast",
+			failFast:   true,
+			downloaded: []string{"hello"},
+			report:     "Downloaded 1 of 4 exercises",
+			err:        "1 of 4 exercises could not be downloaded",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newErr = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			ts := fakeTrackServer([]string{"hello", "leap", "bob", "anagram"}, "leap", "anagram")
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "download-track-failures")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupDownloadTrackFlags(flags)
+			flags.Set("track", "bogus-track")
+			flags.Set("fail-fast", fmt.Sprint(tc.failFast))
+
+			err = runDownloadTrack(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+			if assert.Error(t, err) {
+				assert.Equal(t, tc.err, err.Error())
+			}
+
+			for _, slug := range tc.downloaded {
+				_, err = os.Stat(filepath.Join(tmpDir, "bogus-track", slug, "file.txt"))
+				assert.NoError(t, err, slug)
+			}
+
+			report := Err.(*bytes.Buffer).String()
+			assert.Contains(t, report, tc.report)
+			assert.Contains(t, report, "    leap: no solution for leap")
+			assert.Equal(t, !tc.failFast, strings.Contains(report, "    anagram: no solution for anagram"))
+		})
+	}
+}
+
+func TestDownloadTrackManifest(t *testing.T) {
+	co := newCapturedOutput()
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := fakeTrackServer([]string{"hello", "leap", "bob"}, "leap")
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-track-manifest")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadTrackFlags(flags)
+	flags.Set("track", "bogus-track")
+
+	err = runDownloadTrack(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.Error(t, err)
+
+	manifest, err := loadTrackManifest(filepath.Join(tmpDir, ".download-track-bogus-track.json"), "bogus-track")
+	assert.NoError(t, err)
+	expected := map[string]trackManifestEntry{
+		"hello": {Status: "downloaded", Destination: filepath.Join(tmpDir, "bogus-track", "hello")},
+		"leap":  {Status: "failed", Error: "no solution for leap"},
+		"bob":   {Status: "downloaded", Destination: filepath.Join(tmpDir, "bogus-track", "bob")},
+	}
+	assert.Equal(t, expected, manifest.Exercises)
+}
+
+func TestDownloadTrackResume(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.newErr = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := fakeTrackServer([]string{"hello", "leap", "bob", "anagram"})
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "download-track-resume")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	// An earlier run downloaded hello, failed to download leap, and stopped before bob.
+	manifestPath := filepath.Join(tmpDir, "manifest.json")
+	seeded := &trackManifest{
+		Track: "bogus-track",
+		Exercises: map[string]trackManifestEntry{
+			"hello":   {Status: "downloaded", Destination: filepath.Join(tmpDir, "bogus-track", "hello")},
+			"leap":    {Status: "failed", Error: "no solution for leap"},
+			"anagram": {Status: "downloaded", Destination: filepath.Join(tmpDir, "bogus-track", "anagram")},
+		},
+	}
+	err = seeded.write(manifestPath)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadTrackFlags(flags)
+	flags.Set("track", "bogus-track")
+	flags.Set("manifest", manifestPath)
+	flags.Set("resume", "true")
+	defer func(old bool) { quiet = old }(quiet)
+	quiet = true
+
+	err = runDownloadTrack(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	// Only the exercises that weren't downloaded before are attempted.
+	for slug, attempted := range map[string]bool{"hello": false, "leap": true, "bob": true, "anagram": false} {
+		_, err = os.Stat(filepath.Join(tmpDir, "bogus-track", slug))
+		assert.Equal(t, attempted, err == nil, slug)
+	}
+	expected := []string{filepath.Join(tmpDir, "bogus-track", "leap"), filepath.Join(tmpDir, "bogus-track", "bob")}
+	assert.Equal(t, strings.Join(expected, "\n")+"\n", Out.(*bytes.Buffer).String())
+
+	report := Err.(*bytes.Buffer).String()
+	assert.Contains(t, report, fmt.Sprintf("Skipped 2 exercises that '%s' records as downloaded", manifestPath))
+	assert.Contains(t, report, "Downloaded 4 of 4 exercises in the bogus-track track")
+
+	manifest, err := loadTrackManifest(manifestPath, "bogus-track")
+	assert.NoError(t, err)
+	for _, slug := range []string{"hello", "leap", "bob", "anagram"} {
+		assert.Equal(t, "downloaded", manifest.Exercises[slug].Status, slug)
+	}
+}
+
+func TestDownloadTrackResumeOtherTrack(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "download-track-resume-other")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	manifestPath := filepath.Join(tmpDir, "manifest.json")
+	err = (&trackManifest{Track: "other-track"}).write(manifestPath)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadTrackFlags(flags)
+	flags.Set("track", "bogus-track")
+	flags.Set("manifest", manifestPath)
+	flags.Set("resume", "true")
+
+	err = runDownloadTrack(context.Background(), fakeDownloadConfig(tmpDir, "http://example.com"), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, fmt.Sprintf("the manifest '%s' is of the other-track track, not bogus-track", manifestPath), err.Error())
+	}
+}
+
+func TestDownloadTrackWithoutTrack(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupDownloadTrackFlags(flags)
+
+	err := runDownloadTrack(context.Background(), fakeDownloadConfig("/tmp", "http://example.com"), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "need a --track to download", err.Error())
+	}
+}
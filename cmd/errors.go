@@ -0,0 +1,80 @@
+package cmd
+
+import "errors"
+
+// These are the kinds of error that callers may want to tell apart.
+// Match them with errors.Is; the errors themselves keep their own messages.
+var (
+	// ErrMissingConfig means the user config lacks a required value,
+	// or has one that can't be right.
+	ErrMissingConfig = errors.New("missing user config")
+	// ErrMissingMetadata means an exercise directory has no usable metadata.
+	ErrMissingMetadata = errors.New("missing exercise metadata")
+	// ErrUnauthorized means the API rejected the token.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound means the API has no such solution or exercise.
+	ErrNotFound = errors.New("not found")
+	// ErrRateLimited means the API is refusing requests for now.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrTrackAmbiguous means the exercise exists on several tracks.
+	// The error is an *ambiguousError listing them.
+	ErrTrackAmbiguous = errors.New("track ambiguous")
+	// ErrTeamAmbiguous means the exercise exists on several teams.
+	// The error is an *ambiguousError listing them.
+	ErrTeamAmbiguous = errors.New("team ambiguous")
+	// ErrNetwork means a request didn't get a response.
+	ErrNetwork = errors.New("network error")
+)
+
+// These are the exit codes for the kinds of error that scripts may want to
+// tell apart. Any other error exits with exitFailure.
+const (
+	exitFailure      = -1
+	exitUnauthorized = 3
+	exitNotFound     = 4
+	exitNetwork      = 5
+	exitConfig       = 6
+)
+
+// exitCode gives the exit code for the error's kind, or 0 for no error.
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrUnauthorized):
+		return exitUnauthorized
+	case errors.Is(err, ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, ErrNetwork):
+		return exitNetwork
+	case errors.Is(err, ErrMissingConfig):
+		return exitConfig
+	}
+	return exitFailure
+}
+
+// kindError marks an error as being of a kind, without changing its message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// withKind marks the error as being of the given kind.
+func withKind(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
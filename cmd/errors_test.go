@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithKind(t *testing.T) {
+	cause := errors.New("the original message")
+	err := withKind(ErrNetwork, cause)
+
+	assert.Equal(t, "the original message", err.Error())
+	assert.True(t, errors.Is(err, ErrNetwork))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrUnauthorized))
+
+	assert.Nil(t, withKind(ErrNetwork, nil))
+}
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		err      error
+		expected int
+	}{
+		{desc: "no error", err: nil, expected: 0},
+		{desc: "unauthorized", err: withKind(ErrUnauthorized, errors.New("The token is invalid")), expected: 3},
+		{desc: "not found", err: withKind(ErrNotFound, errors.New("The exercise does not exist")), expected: 4},
+		{desc: "network", err: withKind(ErrNetwork, errors.New("connection refused")), expected: 5},
+		{desc: "config", err: withKind(ErrMissingConfig, errors.New("no token")), expected: 6},
+		{desc: "wrapped", err: fmt.Errorf("unable to download: %w", withKind(ErrNetwork, errors.New("timeout"))), expected: 5},
+		{desc: "ambiguous", err: &ambiguousError{flag: "track"}, expected: -1},
+		{desc: "untyped", err: errors.New("something else"), expected: -1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, exitCode(tc.err))
+		})
+	}
+}
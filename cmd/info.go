@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/exercism/cli/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// infoCmd describes a solution without downloading it.
+var infoCmd = &cobra.Command{
+	Use:   "info",
+	Short: "Show the details of a solution.",
+	Long: `Show the details of a solution, without downloading it.
+
+Pick the solution as you would to download it, by its UUID,
+or by the exercise and optionally its track and team.
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.NewConfig()
+
+		v, err := loadUserConfig(cfg.Dir, cmd.Flags())
+		if err != nil {
+			return err
+		}
+		cfg.UserViperConfig = v
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		return runInfo(ctx, cfg, cmd.Flags(), args)
+	},
+}
+
+// solutionInfo is a solution as reported by the info command.
+type solutionInfo struct {
+	ID          string  `json:"id"`
+	URL         string  `json:"url"`
+	Track       string  `json:"track"`
+	Exercise    string  `json:"exercise"`
+	Handle      string  `json:"handle"`
+	Team        string  `json:"team"`
+	AutoApprove bool    `json:"auto_approve"`
+	SubmittedAt *string `json:"submitted_at"`
+	Files       int     `json:"files"`
+}
+
+func runInfo(ctx context.Context, cfg config.Config, flags *pflag.FlagSet, args []string) error {
+	usrCfg := cfg.UserViperConfig
+	if err := validateUserConfig(usrCfg); err != nil {
+		return err
+	}
+	asJSON, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	downloadFlags := downloadFlagSet(flags)
+	// Nothing is written, so the workspace doesn't need to exist.
+	downloadFlags.Set("dry-run", "true")
+	download, err := newDownload(ctx, downloadFlags, usrCfg)
+	if err != nil {
+		return redactToken(err, usrCfg.GetString("token"))
+	}
+
+	solution := download.payload.Solution
+	info := solutionInfo{
+		ID:          solution.ID,
+		URL:         solution.URL,
+		Track:       solution.Exercise.Track.ID,
+		Exercise:    solution.Exercise.ID,
+		Handle:      solution.User.Handle,
+		Team:        solution.Team.Slug,
+		AutoApprove: solution.Exercise.AutoApprove,
+		SubmittedAt: solution.Iteration.SubmittedAt,
+		Files:       len(solution.Files),
+	}
+
+	if asJSON {
+		return json.NewEncoder(Out).Encode(info)
+	}
+
+	orNone := func(s string) string {
+		if s == "" {
+			return "none"
+		}
+		return s
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	submittedAt := "not submitted"
+	if info.SubmittedAt != nil {
+		submittedAt = *info.SubmittedAt
+	}
+
+	w := tabwriter.NewWriter(Out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "ID:\t%s\n", info.ID)
+	fmt.Fprintf(w, "URL:\t%s\n", orNone(info.URL))
+	fmt.Fprintf(w, "Track:\t%s\n", info.Track)
+	fmt.Fprintf(w, "Exercise:\t%s\n", info.Exercise)
+	fmt.Fprintf(w, "Handle:\t%s\n", info.Handle)
+	fmt.Fprintf(w, "Team:\t%s\n", orNone(info.Team))
+	fmt.Fprintf(w, "Auto-approve:\t%s\n", yesNo(info.AutoApprove))
+	fmt.Fprintf(w, "Submitted at:\t%s\n", submittedAt)
+	fmt.Fprintf(w, "Files:\t%d\n", info.Files)
+	return nil
+}
+
+func setupInfoFlags(flags *pflag.FlagSet) {
+	flags.StringP("uuid", "u", "", "the solution UUID")
+	flags.StringP("track", "t", "", "the track ID")
+	flags.StringP("exercise", "e", "", "the exercise slug")
+	flags.StringP("team", "T", "", "the team slug")
+	flags.StringP("url", "", "", "the solution or exercise URL from the website")
+	flags.BoolP("json", "", false, "print the details as JSON")
+	setupUserConfigFlags(flags)
+	setupClientFlags(flags)
+}
+
+func init() {
+	RootCmd.AddCommand(infoCmd)
+	setupInfoFlags(infoCmd.Flags())
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInfoServer serves a team solution, and fails the test if any file is requested.
+func fakeInfoServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request for %s", r.URL.Path)
+	})
+	mux.HandleFunc("/solutions/bogus-id", func(w http.ResponseWriter, r *http.Request) {
+		payload := fakePayload(server.URL+"/files/", "file.txt", "subdir/nested.txt")
+		payload.Solution.Team.Slug = "bogus-team"
+		payload.Solution.Exercise.AutoApprove = true
+		submittedAt := "2018-01-02T03:04:05Z"
+		payload.Solution.Iteration.SubmittedAt = &submittedAt
+		json.NewEncoder(w).Encode(payload)
+	})
+	return server
+}
+
+func TestInfo(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := fakeInfoServer(t)
+	defer ts.Close()
+
+	// The workspace isn't created, since nothing is downloaded.
+	workspace := filepath.Join(os.TempDir(), "info-workspace-does-not-exist")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupInfoFlags(flags)
+	flags.Set("uuid", "bogus-id")
+
+	err := runInfo(context.Background(), fakeDownloadConfig(workspace, ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	expected := `ID:            bogus-id
+URL:           ` + ts.URL + `/solutions/bogus-id
+Track:         bogus-track
+Exercise:      bogus-exercise
+Handle:        alice
+Team:          bogus-team
+Auto-approve:  yes
+Submitted at:  2018-01-02T03:04:05Z
+Files:         2
+`
+	assert.Equal(t, expected, Out.(*bytes.Buffer).String())
+
+	_, err = os.Stat(workspace)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestInfoJSON(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := fakeInfoServer(t)
+	defer ts.Close()
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupInfoFlags(flags)
+	flags.Set("uuid", "bogus-id")
+	flags.Set("json", "true")
+
+	err := runInfo(context.Background(), fakeDownloadConfig(os.TempDir(), ts.URL), flags, []string{})
+	assert.NoError(t, err)
+
+	expected := `{
+		"id": "bogus-id",
+		"url": "` + ts.URL + `/solutions/bogus-id",
+		"track": "bogus-track",
+		"exercise": "bogus-exercise",
+		"handle": "alice",
+		"team": "bogus-team",
+		"auto_approve": true,
+		"submitted_at": "2018-01-02T03:04:05Z",
+		"files": 2
+	}`
+	assert.JSONEq(t, expected, Out.(*bytes.Buffer).String())
+}
+
+func TestInfoWithoutSolution(t *testing.T) {
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupInfoFlags(flags)
+
+	err := runInfo(context.Background(), fakeDownloadConfig(os.TempDir(), "http://example.com"), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "need an --exercise name or a solution --uuid", err.Error())
+	}
+}
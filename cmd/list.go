@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/exercism/cli/config"
+	"github.com/exercism/cli/workspace"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// listCmd lists the exercises that have been downloaded to the workspace.
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"l", "ls"},
+	Short:   "List the exercises in your workspace.",
+	Long: `List the exercises you have downloaded to your workspace.
+
+The track, exercise, and author of each exercise are read from
+the exercise metadata, along with whether it has been submitted.
+`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.NewConfig()
+
+		v, err := loadUserConfig(cfg.Dir, cmd.Flags())
+		if err != nil {
+			return err
+		}
+		cfg.UserViperConfig = v
+
+		return runList(cfg, cmd.Flags(), args)
+	},
+}
+
+// listedExercise is a downloaded exercise as reported by the list command.
+type listedExercise struct {
+	Track     string `json:"track"`
+	Exercise  string `json:"exercise"`
+	Handle    string `json:"handle"`
+	Submitted bool   `json:"submitted"`
+	Dir       string `json:"dir"`
+}
+
+func runList(cfg config.Config, flags *pflag.FlagSet, args []string) error {
+	usrCfg := cfg.UserViperConfig
+	if userWorkspace(usrCfg) == "" {
+		return fmt.Errorf(msgRerunConfigure, BinaryName)
+	}
+
+	track, err := flags.GetString("track")
+	if err != nil {
+		return err
+	}
+	asJSON, err := flags.GetBool("json")
+	if err != nil {
+		return err
+	}
+
+	ws, err := workspace.New(userWorkspace(usrCfg))
+	if err != nil {
+		return err
+	}
+	// Exercises can be anywhere in the workspace, as where they're
+	// downloaded to depends on the team, the user, and the path template.
+	dirs, err := ws.MetadataDirs()
+	if err != nil {
+		return err
+	}
+
+	listed := make([]listedExercise, 0, len(dirs))
+	for _, dir := range dirs {
+		metadata, err := workspace.NewExerciseMetadata(dir)
+		if err != nil {
+			return err
+		}
+		if track != "" && metadata.Track != track {
+			continue
+		}
+		listed = append(listed, listedExercise{
+			Track:     metadata.Track,
+			Exercise:  metadata.ExerciseSlug,
+			Handle:    metadata.Handle,
+			Submitted: metadata.SubmittedAt != nil,
+			Dir:       metadata.Dir,
+		})
+	}
+
+	if asJSON {
+		return json.NewEncoder(Out).Encode(listed)
+	}
+
+	w := tabwriter.NewWriter(Out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, "TRACK\tEXERCISE\tHANDLE\tSUBMITTED")
+	for _, exercise := range listed {
+		submitted := "no"
+		if exercise.Submitted {
+			submitted = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", exercise.Track, exercise.Exercise, exercise.Handle, submitted)
+	}
+	return nil
+}
+
+func setupListFlags(flags *pflag.FlagSet) {
+	flags.StringP("track", "t", "", "only list exercises in this track")
+	flags.BoolP("json", "", false, "print the exercises as JSON")
+}
+
+func init() {
+	RootCmd.AddCommand(listCmd)
+	setupUserConfigFlags(listCmd.Flags())
+	setupListFlags(listCmd.Flags())
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/exercism/cli/config"
+	"github.com/exercism/cli/workspace"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListWithoutWorkspace(t *testing.T) {
+	cfg := config.Config{
+		UserViperConfig: viper.New(),
+	}
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupListFlags(flags)
+
+	err := runList(cfg, flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "re-run the configure", err.Error())
+	}
+}
+
+func TestList(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	tmpDir := seedListWorkspace(t)
+	defer os.RemoveAll(tmpDir)
+
+	v := viper.New()
+	v.Set("workspace", tmpDir)
+	cfg := config.Config{
+		UserViperConfig: v,
+	}
+
+	testCases := []struct {
+		desc     string
+		track    string
+		expected []string
+	}{
+		{
+			desc:  "all exercises",
+			track: "",
+			expected: []string{
+				"TRACK  EXERCISE  HANDLE  SUBMITTED",
+				"bash   hello     alice   no",
+				"go     leap      alice   yes",
+				"",
+			},
+		},
+		{
+			desc:  "filtered by track",
+			track: "go",
+			expected: []string{
+				"TRACK  EXERCISE  HANDLE  SUBMITTED",
+				"go     leap      alice   yes",
+				"",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			Out = out
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupListFlags(flags)
+			flags.Set("track", tc.track)
+
+			err := runList(cfg, flags, []string{})
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, strings.Split(out.String(), "\n"))
+		})
+	}
+}
+
+func TestListJSON(t *testing.T) {
+	co := newCapturedOutput()
+	co.override()
+	defer co.reset()
+
+	tmpDir := seedListWorkspace(t)
+	defer os.RemoveAll(tmpDir)
+
+	v := viper.New()
+	v.Set("workspace", tmpDir)
+	cfg := config.Config{
+		UserViperConfig: v,
+	}
+
+	out := &bytes.Buffer{}
+	Out = out
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupListFlags(flags)
+	flags.Set("json", "true")
+
+	err := runList(cfg, flags, []string{})
+	assert.NoError(t, err)
+
+	var listed []listedExercise
+	err = json.Unmarshal(out.Bytes(), &listed)
+	assert.NoError(t, err)
+
+	ws, err := workspace.New(tmpDir)
+	assert.NoError(t, err)
+	expected := []listedExercise{
+		{Track: "bash", Exercise: "hello", Handle: "alice", Submitted: false, Dir: filepath.Join(ws.Dir, "bash", "hello")},
+		{Track: "go", Exercise: "leap", Handle: "alice", Submitted: true, Dir: filepath.Join(ws.Dir, "go", "leap")},
+	}
+	assert.Equal(t, expected, listed)
+}
+
+func TestListFindsExercisesAnywhere(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	tmpDir, err := ioutil.TempDir("", "list-cmd-anywhere")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	// As downloaded for a team, for another user, and with a path template.
+	exercises := map[string]workspace.ExerciseMetadata{
+		filepath.Join("teams", "some-team", "go", "leap"):       {Track: "go", ExerciseSlug: "leap", ID: "1", Handle: "alice", Team: "some-team", IsRequester: true},
+		filepath.Join("users", "bob", "go", "hello"):            {Track: "go", ExerciseSlug: "hello", ID: "2", Handle: "bob"},
+		filepath.Join("by-track", "bash", "solutions", "hello"): {Track: "bash", ExerciseSlug: "hello", ID: "3", Handle: "alice", IsRequester: true},
+	}
+	for path, metadata := range exercises {
+		err = metadata.Write(filepath.Join(tmpDir, path))
+		assert.NoError(t, err)
+	}
+
+	v := viper.New()
+	v.Set("workspace", tmpDir)
+	cfg := config.Config{
+		UserViperConfig: v,
+	}
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupListFlags(flags)
+
+	err = runList(cfg, flags, []string{})
+	assert.NoError(t, err)
+	expected := []string{
+		"TRACK  EXERCISE  HANDLE  SUBMITTED",
+		"bash   hello     alice   no",
+		"go     leap      alice   no",
+		"go     hello     bob     no",
+		"",
+	}
+	assert.Equal(t, expected, strings.Split(Out.(*bytes.Buffer).String(), "\n"))
+}
+
+func TestListExpandsWorkspace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("HOME does not determine the home directory on Windows")
+	}
+
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	tmpDir := seedListWorkspace(t)
+	defer os.RemoveAll(tmpDir)
+
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", filepath.Dir(tmpDir))
+
+	// The workspace is found as download finds it.
+	v := viper.New()
+	v.Set("workspace", " ~/"+filepath.Base(tmpDir)+" ")
+	cfg := config.Config{
+		UserViperConfig: v,
+	}
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupListFlags(flags)
+	flags.Set("track", "go")
+
+	err := runList(cfg, flags, []string{})
+	assert.NoError(t, err)
+	expected := []string{
+		"TRACK  EXERCISE  HANDLE  SUBMITTED",
+		"go     leap      alice   yes",
+		"",
+	}
+	assert.Equal(t, expected, strings.Split(Out.(*bytes.Buffer).String(), "\n"))
+}
+
+// seedListWorkspace creates a workspace with two exercises and a directory lacking metadata.
+func seedListWorkspace(t *testing.T) string {
+	tmpDir, err := ioutil.TempDir("", "list-cmd")
+	assert.NoError(t, err)
+
+	submittedAt := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	exercises := []workspace.ExerciseMetadata{
+		{Track: "bash", ExerciseSlug: "hello", ID: "1", Handle: "alice", IsRequester: true},
+		{Track: "go", ExerciseSlug: "leap", ID: "2", Handle: "alice", IsRequester: true, SubmittedAt: &submittedAt},
+	}
+	for _, metadata := range exercises {
+		err = metadata.Write(filepath.Join(tmpDir, metadata.Track, metadata.ExerciseSlug))
+		assert.NoError(t, err)
+	}
+
+	err = os.MkdirAll(filepath.Join(tmpDir, "go", "not-an-exercise"), os.FileMode(0755))
+	assert.NoError(t, err)
+
+	return tmpDir
+}
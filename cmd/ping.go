@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/exercism/cli/api"
+	"github.com/exercism/cli/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// pingCmd checks that downloads would work, without downloading anything.
+var pingCmd = &cobra.Command{
+	Use:     "ping",
+	Aliases: []string{"doctor"},
+	Short:   "Check that the API accepts your token and the workspace is writable.",
+	Long: `Check that the API accepts your token and the workspace is writable.
+
+Run it before a batch of downloads to find problems with the
+configuration up front. It exits with an error if any check fails.
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.NewConfig()
+
+		v, err := loadUserConfig(cfg.Dir, cmd.Flags())
+		if err != nil {
+			return err
+		}
+		cfg.UserViperConfig = v
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		return runPing(ctx, cfg, cmd.Flags(), args)
+	},
+}
+
+func runPing(ctx context.Context, cfg config.Config, flags *pflag.FlagSet, args []string) error {
+	usrCfg := cfg.UserViperConfig
+	if err := validateUserConfig(usrCfg); err != nil {
+		return err
+	}
+	workspace := userWorkspace(usrCfg)
+
+	// The API is reached the same way a download reaches it.
+	client, err := newAPIClient(flags, usrCfg)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(Out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "API:\t%s\n", client.APIBaseURL)
+
+	authErr := checkToken(ctx, client)
+	if authErr != nil {
+		authErr = redactToken(authErr, client.Token)
+		fmt.Fprintf(w, "Token:\t%s\n", authErr)
+	} else {
+		fmt.Fprintf(w, "Token:\taccepted\n")
+	}
+
+	fmt.Fprintf(w, "Workspace:\t%s\n", workspace)
+	writeErr := checkWritable(workspace)
+	if writeErr != nil {
+		fmt.Fprintf(w, "Writable:\tno, %s\n", writeErr)
+	} else {
+		fmt.Fprintf(w, "Writable:\tyes\n")
+	}
+	w.Flush()
+
+	if authErr != nil {
+		return authErr
+	}
+	return writeErr
+}
+
+// checkToken asks the API whether it accepts the client's token.
+// Only a 401 or 403 response means that the token was rejected; any other
+// failure says nothing about the token, as when the API is down.
+func checkToken(ctx context.Context, client *api.Client) error {
+	res, err := client.ValidateToken(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return withKind(ErrNetwork, fmt.Errorf("the API can't be reached: %w", err))
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return withKind(ErrUnauthorized, errors.New("rejected by the API"))
+	case http.StatusTooManyRequests:
+		return withKind(ErrRateLimited, errors.New("the API is rate limiting requests, please try again later"))
+	case http.StatusNotFound:
+		return withKind(ErrNotFound, fmt.Errorf("the API isn't at %s, check the apibaseurl in the user config", client.APIBaseURL))
+	}
+	err = decodeAPIError(res)
+	var unexplained *unexplainedAPIError
+	if errors.As(err, &unexplained) {
+		return fmt.Errorf("the API couldn't check it, it answered %s", res.Status)
+	}
+	return fmt.Errorf("the API couldn't check it: %w", err)
+}
+
+// checkWritable makes sure that a file can be created in the directory.
+func checkWritable(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return errors.New("the workspace does not exist")
+	}
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("the workspace is not a directory")
+	}
+
+	f, err := ioutil.TempFile(dir, ".exercism-ping-")
+	if err != nil {
+		return fmt.Errorf("the workspace is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func init() {
+	RootCmd.AddCommand(pingCmd)
+	setupUserConfigFlags(pingCmd.Flags())
+	setupClientFlags(pingCmd.Flags())
+}
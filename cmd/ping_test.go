@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPing(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		status   int
+		kind     error
+		err      string
+		expected string
+	}{
+		{
+			desc:     "authorized",
+			status:   http.StatusOK,
+			expected: "accepted",
+		},
+		{
+			desc:     "unauthorized",
+			status:   http.StatusUnauthorized,
+			kind:     ErrUnauthorized,
+			err:      "rejected by the API",
+			expected: "rejected by the API",
+		},
+		{
+			desc:     "forbidden",
+			status:   http.StatusForbidden,
+			kind:     ErrUnauthorized,
+			err:      "rejected by the API",
+			expected: "rejected by the API",
+		},
+		{
+			desc:     "rate limited",
+			status:   http.StatusTooManyRequests,
+			kind:     ErrRateLimited,
+			err:      "the API is rate limiting requests, please try again later",
+			expected: "the API is rate limiting requests, please try again later",
+		},
+		{
+			desc:     "wrong apibaseurl",
+			status:   http.StatusNotFound,
+			kind:     ErrNotFound,
+			err:      "the API isn't at {{URL}}, check the apibaseurl in the user config",
+			expected: "the API isn't at {{URL}}, check the apibaseurl in the user config",
+		},
+		{
+			desc:     "server error",
+			status:   http.StatusServiceUnavailable,
+			err:      "the API couldn't check it, it answered 503 Service Unavailable",
+			expected: "the API couldn't check it, it answered 503 Service Unavailable",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.newOut = &bytes.Buffer{}
+			co.override()
+			defer co.reset()
+
+			var auth string
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/validate_token" {
+					t.Errorf("unexpected request for %s", r.URL.Path)
+				}
+				auth = r.Header.Get("Authorization")
+				w.WriteHeader(tc.status)
+			}))
+			defer ts.Close()
+
+			tmpDir, err := ioutil.TempDir("", "ping")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			cfg := fakeDownloadConfig(tmpDir, ts.URL)
+			cfg.UserViperConfig.Set("maxretries", 0)
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			err = runPing(context.Background(), cfg, flags, []string{})
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, strings.Replace(tc.err, "{{URL}}", ts.URL, 1), err.Error())
+				if tc.kind != nil {
+					assert.True(t, errors.Is(err, tc.kind))
+				} else {
+					assert.False(t, errors.Is(err, ErrUnauthorized))
+				}
+			}
+			assert.Equal(t, "Bearer abc123", auth)
+
+			expected := "API:        " + ts.URL + "\n" +
+				"Token:      " + strings.Replace(tc.expected, "{{URL}}", ts.URL, 1) + "\n" +
+				"Workspace:  " + tmpDir + "\n" +
+				"Writable:   yes\n"
+			assert.Equal(t, expected, Out.(*bytes.Buffer).String())
+
+			// The check leaves nothing behind.
+			entries, err := ioutil.ReadDir(tmpDir)
+			assert.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestPingConnectsAsDownload(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	var userAgent, auth string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		auth = r.Header.Get("Authorization")
+	}))
+	defer ts.Close()
+
+	tmpDir, err := ioutil.TempDir("", "ping")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	cfg := fakeDownloadConfig(tmpDir, ts.URL+"/")
+	cfg.UserViperConfig.Set("useragent", "from-config")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupClientFlags(flags)
+	flags.Set("token", "from-flag")
+
+	err = runPing(context.Background(), cfg, flags, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "from-config", userAgent)
+	assert.Equal(t, "Bearer from-flag", auth)
+	assert.Contains(t, Out.(*bytes.Buffer).String(), "API:        "+ts.URL+"\n")
+}
+
+func TestPingWithBadProxy(t *testing.T) {
+	cfg := fakeDownloadConfig(os.TempDir(), "http://example.com")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupClientFlags(flags)
+	flags.Set("proxy", "ftp://proxy.example.com")
+
+	err := runPing(context.Background(), cfg, flags, []string{})
+	if assert.Error(t, err) {
+		assert.Equal(t, "--proxy: 'ftp://proxy.example.com' must use http, https, or socks5", err.Error())
+	}
+}
+
+func TestPingUnreachable(t *testing.T) {
+	co := newCapturedOutput()
+	co.newOut = &bytes.Buffer{}
+	co.override()
+	defer co.reset()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	workspace := filepath.Join(os.TempDir(), "ping-workspace-does-not-exist")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupClientFlags(flags)
+	flags.Set("max-retries", "0")
+	err := runPing(context.Background(), fakeDownloadConfig(workspace, ts.URL), flags, []string{})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "^the API can't be reached: ", err.Error())
+		assert.True(t, errors.Is(err, ErrNetwork))
+	}
+
+	out := Out.(*bytes.Buffer).String()
+	assert.Regexp(t, "(?m)^Token:      the API can't be reached: ", out)
+	assert.Contains(t, out, "Workspace:  "+workspace+"\nWritable:   no, the workspace does not exist\n")
+}
+
+func TestPingWithoutToken(t *testing.T) {
+	cfg := fakeDownloadConfig(os.TempDir(), "http://example.com")
+	cfg.UserViperConfig.Set("token", "")
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	err := runPing(context.Background(), cfg, flags, []string{})
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, ErrMissingConfig))
+	}
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/exercism/cli/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// refreshCmd restores the original files of a downloaded exercise.
+var refreshCmd = &cobra.Command{
+	Use:     "refresh [DIR]",
+	Aliases: []string{"r"},
+	Short:   "Restore the original files of an exercise.",
+	Long: `Restore the original files of a downloaded exercise.
+
+Pass the path to the exercise directory, or run the command from
+inside it. The files that came with the exercise are downloaded
+again, and any files you have added are left alone.
+
+Files you have changed are kept, unless you pass --force.
+`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg := config.NewConfig()
+
+		v, err := loadUserConfig(cfg.Dir, cmd.Flags())
+		if err != nil {
+			return err
+		}
+		cfg.UserViperConfig = v
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		return runRefresh(ctx, cfg, cmd.Flags(), args)
+	},
+}
+
+func runRefresh(ctx context.Context, cfg config.Config, flags *pflag.FlagSet, args []string) error {
+	if err := validateUserConfig(cfg.UserViperConfig); err != nil {
+		return err
+	}
+
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return fmt.Errorf("'%s' is not a directory", dir)
+	}
+
+	download, err := newDownloadFromExercise(ctx, dir, flags, cfg.UserViperConfig)
+	if err != nil {
+		return redactToken(err, cfg.UserViperConfig.GetString("token"))
+	}
+
+	written, err := download.writeSolutionFiles(ctx, dir)
+	if err != nil {
+		return redactToken(err, cfg.UserViperConfig.GetString("token"))
+	}
+
+	fmt.Fprintf(Err, "\nRestored %d of %d files in\n", len(written), len(download.payload.files()))
+	fmt.Fprintf(Out, "%s\n", dir)
+	return nil
+}
+
+func setupRefreshFlags(flags *pflag.FlagSet) {
+	flags.BoolP("force", "F", false, "replace files that have local changes")
+	setupUserConfigFlags(flags)
+	setupClientFlags(flags)
+}
+
+func init() {
+	RootCmd.AddCommand(refreshCmd)
+	setupRefreshFlags(refreshCmd.Flags())
+}
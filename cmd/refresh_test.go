@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/exercism/cli/workspace"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRefreshWithoutMetadata(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "refresh-without-metadata")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+	setupRefreshFlags(flags)
+
+	err = runRefresh(context.Background(), fakeDownloadConfig(tmpDir, "http://example.com"), flags, []string{tmpDir})
+	if assert.Error(t, err) {
+		assert.Regexp(t, "not a downloaded exercise", err.Error())
+		assert.True(t, errors.Is(err, ErrMissingMetadata))
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		force          bool
+		expectedEdited string
+	}{
+		{desc: "keeps local changes", force: false, expectedEdited: "my changes"},
+		{desc: "replaces local changes with --force", force: true, expectedEdited: "original edited.txt"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			co := newCapturedOutput()
+			co.override()
+			defer co.reset()
+
+			var requested string
+			mux := http.NewServeMux()
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+			mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, "original %s", filepath.Base(r.URL.Path))
+			})
+			mux.HandleFunc("/solutions/", func(w http.ResponseWriter, r *http.Request) {
+				requested = r.URL.Path
+				json.NewEncoder(w).Encode(fakePayload(ts.URL+"/files/", "deleted.txt", "edited.txt", "untouched.txt"))
+			})
+
+			tmpDir, err := ioutil.TempDir("", "refresh")
+			defer os.RemoveAll(tmpDir)
+			assert.NoError(t, err)
+
+			dir := filepath.Join(tmpDir, "bogus-track", "bogus-exercise")
+			metadata := &workspace.ExerciseMetadata{
+				ID:           "bogus-id",
+				Track:        "bogus-track",
+				ExerciseSlug: "bogus-exercise",
+				IsRequester:  true,
+			}
+			err = metadata.Write(dir)
+			assert.NoError(t, err)
+			for name, contents := range map[string]string{
+				"edited.txt":    "my changes",
+				"untouched.txt": "original untouched.txt",
+				"mine.txt":      "my own file",
+			} {
+				err = ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), os.FileMode(0644))
+				assert.NoError(t, err)
+			}
+
+			flags := pflag.NewFlagSet("fake", pflag.PanicOnError)
+			setupRefreshFlags(flags)
+			flags.Set("force", fmt.Sprint(tc.force))
+
+			err = runRefresh(context.Background(), fakeDownloadConfig(tmpDir, ts.URL), flags, []string{dir})
+			assert.NoError(t, err)
+			assert.Equal(t, "/solutions/bogus-id", requested)
+
+			for name, expected := range map[string]string{
+				"deleted.txt":   "original deleted.txt",
+				"edited.txt":    tc.expectedEdited,
+				"untouched.txt": "original untouched.txt",
+				"mine.txt":      "my own file",
+			} {
+				b, err := ioutil.ReadFile(filepath.Join(dir, name))
+				assert.NoError(t, err, name)
+				assert.Equal(t, expected, string(b), name)
+			}
+		})
+	}
+}
@@ -18,7 +18,14 @@ var RootCmd = &cobra.Command{
 	Short: "A friendly command-line interface to Exercism.",
 	Long: `A command-line interface for the v2 redesign of Exercism.
 
-Download exercises and submit your solutions.`,
+Download exercises and submit your solutions.
+
+A command that fails exits with one of these codes:
+  3    the API rejected the token
+  4    the solution or exercise wasn't found
+  5    the API couldn't be reached
+  6    the user config is missing a value, run the configure command
+  255  any other error`,
 	SilenceUsage: true,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		if verbose, _ := cmd.Flags().GetBool("verbose"); verbose {
@@ -27,6 +34,15 @@ Download exercises and submit your solutions.`,
 		if unmask, _ := cmd.Flags().GetBool("unmask-token"); unmask {
 			debug.UnmaskAPIKey = unmask
 		}
+		if nc, _ := cmd.Flags().GetBool("no-color"); nc {
+			noColor = nc
+		}
+		if q, _ := cmd.Flags().GetBool("quiet"); q {
+			quiet = q
+		}
+		if i, _ := cmd.Flags().GetBool("insecure"); i {
+			insecure = i
+		}
 		if timeout, _ := cmd.Flags().GetInt("timeout"); timeout > 0 {
 			cli.TimeoutInSeconds = timeout
 			api.TimeoutInSeconds = timeout
@@ -37,7 +53,7 @@ Download exercises and submit your solutions.`,
 // Execute adds all child commands to the root command.
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
-		os.Exit(-1)
+		os.Exit(exitCode(err))
 	}
 }
 
@@ -46,8 +62,12 @@ func init() {
 	config.SetDefaultDirName(BinaryName)
 	Out = os.Stdout
 	Err = os.Stderr
+	In = os.Stdin
 	api.UserAgent = fmt.Sprintf("github.com/exercism/cli v%s (%s/%s)", Version, runtime.GOOS, runtime.GOARCH)
 	RootCmd.PersistentFlags().BoolP("verbose", "v", false, "verbose output")
 	RootCmd.PersistentFlags().IntP("timeout", "", 0, "override the default HTTP timeout (seconds)")
 	RootCmd.PersistentFlags().BoolP("unmask-token", "", false, "will unmask the API during a request/response dump")
+	RootCmd.PersistentFlags().BoolP("no-color", "", false, "don't color the output")
+	RootCmd.PersistentFlags().BoolP("quiet", "q", false, "don't write progress and other informational output")
+	RootCmd.PersistentFlags().BoolP("insecure", "", false, "don't verify TLS certificates, for testing only")
 }
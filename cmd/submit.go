@@ -8,6 +8,7 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/exercism/cli/api"
 	"github.com/exercism/cli/config"
@@ -164,7 +165,7 @@ func (s *submitCmdContext) removeDuplicatePaths(submitPaths []string) []string {
 // exercise creates an exercise using one of the submitted filepaths.
 // This assumes prior verification that submit paths belong to the same exercise.
 func (s *submitCmdContext) exercise(aSubmitPath string) (workspace.Exercise, error) {
-	ws, err := workspace.New(s.usrCfg.GetString("workspace"))
+	ws, err := workspace.New(userWorkspace(s.usrCfg))
 	if err != nil {
 		return workspace.Exercise{}, err
 	}
@@ -250,7 +251,7 @@ func (s *submitCmdContext) submit(metadata *workspace.ExerciseMetadata, docs []w
 		return err
 	}
 
-	client, err := api.NewClient(s.usrCfg.GetString("token"), s.usrCfg.GetString("apibaseurl"))
+	client, err := api.NewClient(strings.TrimSpace(s.usrCfg.GetString("token")), s.usrCfg.GetString("apibaseurl"))
 	if err != nil {
 		return err
 	}
@@ -340,7 +341,7 @@ func (s submitValidator) filesExistAndNotADir(submitPaths []string) error {
 
 // filesBelongToSameExercise checks that each file belongs to the same exercise.
 func (s submitValidator) filesBelongToSameExercise(submitPaths []string) error {
-	ws, err := workspace.New(s.usrCfg.GetString("workspace"))
+	ws, err := workspace.New(userWorkspace(s.usrCfg))
 	if err != nil {
 		return err
 	}
@@ -350,7 +351,7 @@ func (s submitValidator) filesBelongToSameExercise(submitPaths []string) error {
 		dir, err := ws.ExerciseDir(f)
 		if err != nil {
 			if workspace.IsMissingMetadata(err) {
-				return errors.New(msgMissingMetadata)
+				return withKind(ErrMissingMetadata, errors.New(msgMissingMetadata))
 			}
 			return err
 		}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -105,6 +106,7 @@ func TestSubmitExerciseWithoutMetadataFile(t *testing.T) {
 	err = runSubmit(cfg, pflag.NewFlagSet("fake", pflag.PanicOnError), []string{file})
 	if assert.Error(t, err) {
 		assert.Regexp(t, "doesn't have the necessary metadata", err.Error())
+		assert.True(t, errors.Is(err, ErrMissingMetadata))
 	}
 }
 
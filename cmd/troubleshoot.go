@@ -181,7 +181,7 @@ func newSystemStatus() systemStatus {
 func newConfigurationStatus(status *Status) configurationStatus {
 	v := status.cfg.UserViperConfig
 
-	workspace := v.GetString("workspace")
+	workspace := userWorkspace(v)
 	if workspace == "" {
 		workspace = fmt.Sprintf("%s (default)", config.DefaultWorkspaceDir(status.cfg))
 	}
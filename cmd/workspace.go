@@ -36,7 +36,7 @@ nothing will happen.
 		// Ignore error. If the file doesn't exist, that is fine.
 		_ = v.ReadInConfig()
 
-		fmt.Fprintf(Out, "%s\n", v.GetString("workspace"))
+		fmt.Fprintf(Out, "%s\n", userWorkspace(v))
 		return nil
 	},
 }
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"os/user"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTilde(t *testing.T) {
+	current, err := user.Current()
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"~", "/home/alice"},
+		{"~/exercism", "/home/alice/exercism"},
+		{"~" + current.Username + "/exercism", current.HomeDir + "/exercism"},
+		{"~no-such-user-here/exercism", "~no-such-user-here/exercism"},
+		{"/already/absolute", "/already/absolute"},
+		{"relative/~/path", "relative/~/path"},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.out, expandTilde(tc.in, "/home/alice"), tc.in)
+	}
+}
+
+func TestExpandWindowsVars(t *testing.T) {
+	os.Setenv("EXERCISM_TEST_DIR", `C:\Users\alice`)
+	defer os.Unsetenv("EXERCISM_TEST_DIR")
+
+	testCases := []struct {
+		in, out string
+	}{
+		{`%EXERCISM_TEST_DIR%\exercism`, `C:\Users\alice\exercism`},
+		{`%EXERCISM_NO_SUCH_VAR%\exercism`, `%EXERCISM_NO_SUCH_VAR%\exercism`},
+		{`C:\already\absolute`, `C:\already\absolute`},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.out, expandWindowsVars(tc.in), tc.in)
+	}
+}
+
+func TestExpandEnvVars(t *testing.T) {
+	os.Setenv("EXERCISM_TEST_DIR", "/srv/alice")
+	defer os.Unsetenv("EXERCISM_TEST_DIR")
+
+	assert.Equal(t, "/srv/alice/exercism", Expand("$EXERCISM_TEST_DIR/exercism"))
+	assert.Equal(t, "/srv/alice/exercism", Expand("${EXERCISM_TEST_DIR}/exercism"))
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"os/user"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strings"
 )
 
@@ -28,3 +31,43 @@ func Resolve(path, home string) string {
 	}
 	return filepath.Join(cwd, path)
 }
+
+// Expand expands a leading ~ or ~user to a home directory, and environment
+// variables written as $VAR or ${VAR} (or %VAR% on Windows).
+// Anything that cannot be expanded is left as it is.
+func Expand(path string) string {
+	if runtime.GOOS == "windows" {
+		path = expandWindowsVars(path)
+	}
+	path = os.ExpandEnv(path)
+	return expandTilde(path, userHome())
+}
+
+func expandTilde(path, home string) string {
+	if !strings.HasPrefix(path, "~") {
+		return path
+	}
+	name, rest := path[1:], ""
+	if i := strings.IndexAny(name, `/\`); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+	if name == "" {
+		return home + rest
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return path
+	}
+	return u.HomeDir + rest
+}
+
+var windowsVar = regexp.MustCompile(`%([^%]+)%`)
+
+func expandWindowsVars(path string) string {
+	return windowsVar.ReplaceAllStringFunc(path, func(match string) string {
+		if value, ok := os.LookupEnv(match[1 : len(match)-1]); ok {
+			return value
+		}
+		return match
+	})
+}
@@ -72,10 +72,7 @@ func DumpResponse(res *http.Response) {
 		return
 	}
 
-	var bodyCopy bytes.Buffer
-	body := io.TeeReader(res.Body, &bodyCopy)
-	res.Body = ioutil.NopCloser(body)
-
+	// DumpResponse restores the body it reads.
 	dump, err := httputil.DumpResponse(res, res.ContentLength > 0)
 	if err != nil {
 		log.Fatal(err)
@@ -85,12 +82,14 @@ func DumpResponse(res *http.Response) {
 	Println(string(dump))
 	Println("========================= END DumpResponse =========================")
 	Println("")
-
-	res.Body = ioutil.NopCloser(body)
 }
 
 // Redact masks the given token by replacing part of the string with *
+// Tokens too short to keep their ends private are masked entirely.
 func Redact(token string) string {
+	if len(token) < 8 {
+		return strings.Repeat("*", len(token))
+	}
 	str := token[4 : len(token)-3]
 	redaction := strings.Repeat("*", len(str))
 	return string(token[:4]) + redaction + string(token[len(token)-3:])
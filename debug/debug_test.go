@@ -2,7 +2,9 @@ package debug
 
 import (
 	"bytes"
+	"io/ioutil"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -100,9 +102,30 @@ func TestDumpResponse(t *testing.T) {
 	assert.Regexp(t, "HTTP/1.1 200 OK", b.String())
 }
 
+func TestDumpResponsePreservesBody(t *testing.T) {
+	output = &bytes.Buffer{}
+	Verbose = true
+	r := &http.Response{
+		StatusCode:    200,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		ContentLength: 5,
+		Body:          ioutil.NopCloser(strings.NewReader("hello")),
+	}
+
+	DumpResponse(r)
+	body, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
+
 func TestRedact(t *testing.T) {
 	fakeToken := "1a11111aaaa111aa1a11111a11111aa1"
 	expected := "1a11*************************aa1"
 
 	assert.Equal(t, expected, Redact(fakeToken))
 }
+
+func TestRedactShortToken(t *testing.T) {
+	assert.Equal(t, "******", Redact("abc123"))
+}
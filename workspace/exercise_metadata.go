@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 )
@@ -28,6 +29,14 @@ type ExerciseMetadata struct {
 	SubmittedAt  *time.Time `json:"submitted_at,omitempty"`
 	Dir          string     `json:"-"`
 	AutoApprove  bool       `json:"auto_approve"`
+	DownloadedAt *time.Time `json:"downloaded_at,omitempty"`
+	APIBaseURL   string     `json:"api_base_url,omitempty"`
+	// Renamed maps the paths of files written under another name to the
+	// paths they were written to.
+	Renamed map[string]string `json:"renamed,omitempty"`
+	// SHA256 maps the paths of the downloaded files to the hex SHA-256
+	// checksums of their contents as written, to detect later changes.
+	SHA256 map[string]string `json:"sha256,omitempty"`
 }
 
 // NewExerciseMetadata reads exercise metadata from a file in the given directory.
@@ -63,22 +72,97 @@ func (em *ExerciseMetadata) String() string {
 }
 
 // Write stores exercise metadata to a file.
+// The file is replaced atomically, so an interrupted write can't leave it truncated.
+// Fields of an existing file that this version doesn't know about are kept,
+// since a newer version of the CLI may have written them.
 func (em *ExerciseMetadata) Write(dir string) error {
-	b, err := json.Marshal(em)
+	metadataAbsoluteFilepath := filepath.Join(dir, metadataFilepath)
+	b, err := em.merge(metadataAbsoluteFilepath)
 	if err != nil {
 		return err
 	}
-	metadataAbsoluteFilepath := filepath.Join(dir, metadataFilepath)
 	if err = os.MkdirAll(filepath.Dir(metadataAbsoluteFilepath), os.FileMode(0755)); err != nil {
 		return err
 	}
-	if err = ioutil.WriteFile(metadataAbsoluteFilepath, b, os.FileMode(0600)); err != nil {
+	if err = writeFileAtomically(metadataAbsoluteFilepath, b); err != nil {
 		return err
 	}
 	em.Dir = dir
 	return nil
 }
 
+// merge encodes the metadata along with any unknown fields of the existing file at path.
+// If there is no readable existing file, the metadata is encoded on its own.
+func (em *ExerciseMetadata) merge(path string) ([]byte, error) {
+	b, err := json.Marshal(em)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := ioutil.ReadFile(path)
+	if err != nil {
+		return b, nil
+	}
+	var unknown map[string]json.RawMessage
+	if err := json.Unmarshal(existing, &unknown); err != nil {
+		return b, nil
+	}
+	for name := range metadataFields() {
+		delete(unknown, name)
+	}
+	if len(unknown) == 0 {
+		return b, nil
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(b, &merged); err != nil {
+		return nil, err
+	}
+	for name, value := range unknown {
+		merged[name] = value
+	}
+	return json.Marshal(merged)
+}
+
+// metadataFields returns the JSON names of the fields of ExerciseMetadata.
+func metadataFields() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeOf(ExerciseMetadata{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// writeFileAtomically writes to a temporary file next to the path,
+// and then renames it into place. The temporary file is removed on failure.
+func writeFileAtomically(path string, b []byte) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
 // PathToParent is the relative path from the workspace to the parent dir.
 func (em *ExerciseMetadata) PathToParent() string {
 	var dir string
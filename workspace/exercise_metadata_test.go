@@ -1,8 +1,10 @@
 package workspace
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -40,6 +42,117 @@ func TestExerciseMetadata(t *testing.T) {
 	em3, err := NewExerciseMetadata(dir)
 	assert.NoError(t, err)
 	assert.Equal(t, em2, em3)
+
+	downloadedAt := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	em3.DownloadedAt = &downloadedAt
+	em3.APIBaseURL = "http://example.com/v1"
+
+	err = em3.Write(dir)
+	assert.NoError(t, err)
+
+	em4, err := NewExerciseMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, em3, em4)
+}
+
+func TestExerciseMetadataWriteFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "solution")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// A directory in the way of the metadata file makes the final rename fail.
+	blocker := filepath.Join(dir, metadataFilepath, "blocker")
+	err = os.MkdirAll(blocker, os.FileMode(0755))
+	assert.NoError(t, err)
+
+	em := &ExerciseMetadata{Track: "a-track", ExerciseSlug: "bogus-exercise", ID: "abc"}
+	err = em.Write(dir)
+	assert.Error(t, err)
+	assert.Equal(t, "", em.Dir)
+
+	entries, err := ioutil.ReadDir(filepath.Join(dir, ignoreSubdir))
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, metadataFilename, entries[0].Name())
+		assert.True(t, entries[0].IsDir())
+	}
+}
+
+func TestExerciseMetadataWriteReplacesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "solution")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	em := &ExerciseMetadata{Track: "a-track", ExerciseSlug: "bogus-exercise", ID: "abc"}
+	assert.NoError(t, em.Write(dir))
+	em.ID = "def"
+	assert.NoError(t, em.Write(dir))
+
+	entries, err := ioutil.ReadDir(filepath.Join(dir, ignoreSubdir))
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(entries)) {
+		assert.Equal(t, metadataFilename, entries[0].Name())
+		assert.Equal(t, os.FileMode(0600), entries[0].Mode().Perm())
+	}
+
+	written, err := NewExerciseMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "def", written.ID)
+}
+
+func TestExerciseMetadataWriteKeepsUnknownFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "solution")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	existing := `{"track":"old-track","exercise":"bogus-exercise","id":"abc","team":"red","handle":"alice","from_the_future":{"enabled":true},"notes":"keep me"}`
+	err = os.MkdirAll(filepath.Join(dir, ignoreSubdir), os.FileMode(0755))
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, metadataFilepath), []byte(existing), os.FileMode(0600))
+	assert.NoError(t, err)
+
+	em := &ExerciseMetadata{Track: "a-track", ExerciseSlug: "bogus-exercise", ID: "def", Handle: "alice"}
+	err = em.Write(dir)
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, metadataFilepath))
+	assert.NoError(t, err)
+	var fields map[string]interface{}
+	err = json.Unmarshal(b, &fields)
+	assert.NoError(t, err)
+
+	// New values win, including leaving out fields that are now empty.
+	assert.Equal(t, "a-track", fields["track"])
+	assert.Equal(t, "def", fields["id"])
+	_, ok := fields["team"]
+	assert.False(t, ok)
+	// Fields this version doesn't know about survive.
+	assert.Equal(t, map[string]interface{}{"enabled": true}, fields["from_the_future"])
+	assert.Equal(t, "keep me", fields["notes"])
+
+	written, err := NewExerciseMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "def", written.ID)
+	assert.Equal(t, "", written.Team)
+}
+
+func TestExerciseMetadataWriteOverUnreadableFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "solution")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = os.MkdirAll(filepath.Join(dir, ignoreSubdir), os.FileMode(0755))
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(filepath.Join(dir, metadataFilepath), []byte(`{"track":`), os.FileMode(0600))
+	assert.NoError(t, err)
+
+	em := &ExerciseMetadata{Track: "a-track", ExerciseSlug: "bogus-exercise", ID: "def"}
+	err = em.Write(dir)
+	assert.NoError(t, err)
+
+	written, err := NewExerciseMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, "a-track", written.Track)
 }
 
 func TestSuffix(t *testing.T) {
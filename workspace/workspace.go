@@ -119,6 +119,34 @@ func (ws Workspace) Exercises() ([]Exercise, error) {
 	return exercises, nil
 }
 
+// MetadataDirs walks the whole workspace for exercise metadata, and returns
+// the directories of the exercises that have it, wherever they are: below
+// teams or users, or at a path of the user's choosing. Hidden directories,
+// such as a .git directory, aren't searched, nor are the exercises themselves.
+func (ws Workspace) MetadataDirs() ([]string, error) {
+	dirs := []string{}
+	err := filepath.Walk(ws.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != ws.Dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if _, err := os.Lstat(filepath.Join(path, metadataFilepath)); err == nil {
+			dirs = append(dirs, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dirs, nil
+}
+
 // ExerciseDir determines the root directory of an exercise.
 // This is the directory that contains the exercise metadata file.
 func (ws Workspace) ExerciseDir(s string) (string, error) {
@@ -92,6 +92,45 @@ func TestWorkspaceExercises(t *testing.T) {
 	}
 }
 
+func TestWorkspaceMetadataDirs(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "walk-for-metadata")
+	defer os.RemoveAll(tmpDir)
+	assert.NoError(t, err)
+
+	a1 := filepath.Join(tmpDir, "track-a", "exercise-one")
+	a2 := filepath.Join(tmpDir, "track-a", "exercise-two") // no metadata
+	team := filepath.Join(tmpDir, "teams", "some-team", "track-b", "exercise-one")
+	alice := filepath.Join(tmpDir, "users", "alice", "track-a", "exercise-one")
+	custom := filepath.Join(tmpDir, "by-track", "track-c", "solutions", "exercise-one")
+	// Neither hidden directories nor directories within exercises are searched.
+	hidden := filepath.Join(tmpDir, ".git", "track-a", "exercise-one")
+	nested := filepath.Join(a1, "subdir", "exercise-one")
+
+	for _, path := range []string{a1, a2, team, alice, custom, hidden, nested} {
+		metadataAbsoluteFilepath := filepath.Join(path, metadataFilepath)
+		err := os.MkdirAll(filepath.Dir(metadataAbsoluteFilepath), os.FileMode(0755))
+		assert.NoError(t, err)
+
+		if path != a2 {
+			err = ioutil.WriteFile(metadataAbsoluteFilepath, []byte{}, os.FileMode(0600))
+			assert.NoError(t, err)
+		}
+	}
+
+	ws, err := New(tmpDir)
+	assert.NoError(t, err)
+
+	dirs, err := ws.MetadataDirs()
+	assert.NoError(t, err)
+	expected := []string{
+		filepath.Join(ws.Dir, "by-track", "track-c", "solutions", "exercise-one"),
+		filepath.Join(ws.Dir, "teams", "some-team", "track-b", "exercise-one"),
+		filepath.Join(ws.Dir, "track-a", "exercise-one"),
+		filepath.Join(ws.Dir, "users", "alice", "track-a", "exercise-one"),
+	}
+	assert.Equal(t, expected, dirs)
+}
+
 func TestExerciseDir(t *testing.T) {
 	_, cwd, _, _ := runtime.Caller(0)
 	root := filepath.Join(cwd, "..", "..", "fixtures", "solution-dir")